@@ -3,92 +3,40 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"os"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
 )
 
-const defaultRegion = "us-west-2"
+const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
 
-var brc *bedrockruntime.Client
+//const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
 
-func init() {
+func main() {
+	cfg := bedrock.DefaultConfig(modelID)
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = defaultRegion
+	err := cfg.Validate()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	client, err := bedrock.NewClientFromConfig(context.Background(), cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	brc = bedrockruntime.NewFromConfig(cfg)
-}
-
-const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
-
-//const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
-
-type Claude3Request struct {
-	AnthropicVersion string    `json:"anthropic_version"`
-	MaxTokens        int       `json:"max_tokens"`
-	Messages         []Message `json:"messages"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	TopK             int       `json:"top_k,omitempty"`
-	StopSequences    []string  `json:"stop_sequences,omitempty"`
-	SystemPrompt     string    `json:"system,omitempty"`
-}
-type Content struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Message struct {
-	Role    string    `json:"role,omitempty"`
-	Content []Content `json:"content,omitempty"`
-}
-
-type Claude3Response struct {
-	ID              string            `json:"id,omitempty"`
-	Model           string            `json:"model,omitempty"`
-	Type            string            `json:"type,omitempty"`
-	Role            string            `json:"role,omitempty"`
-	ResponseContent []ResponseContent `json:"content,omitempty"`
-	StopReason      string            `json:"stop_reason,omitempty"`
-	StopSequence    string            `json:"stop_sequence,omitempty"`
-	Usage           Usage             `json:"usage,omitempty"`
-}
-type ResponseContent struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Usage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
-}
-
-func main() {
-
 	msg := "Hello, what's your name?"
 
-	payload := Claude3Request{
-		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        1024,
-		Messages: []Message{
-			{
-				Role: "user",
-				Content: []Content{
-					{
-						Type: "text",
-						Text: msg,
-					},
-				},
+	payload := cfg.Request()
+	payload.Messages = []bedrock.Message{
+		{
+			Role: "user",
+			Content: []bedrock.Content{
+				bedrock.TextContent(msg),
 			},
 		},
 	}
@@ -99,26 +47,17 @@ func main() {
 	}
 	fmt.Println("request payload:\n", string(payloadBytes))
 
-	output, err := brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-	})
-
+	resp, err := client.Invoke(context.Background(), payload)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var resp Claude3Response
-
-	err = json.Unmarshal(output.Body, &resp)
-
+	respBytes, err := json.Marshal(resp)
 	if err != nil {
 		log.Fatal(err)
 	}
+	fmt.Println("response payload:\n", string(respBytes))
 
-	fmt.Println("response payload:\n", string(output.Body))
-
-	fmt.Println("response string:\n", resp.ResponseContent[0].Text)
+	fmt.Println("response string:\n", resp.Text())
 
 }
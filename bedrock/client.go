@@ -0,0 +1,292 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// Invoker is the part of the Bedrock runtime API used by Client. It is satisfied by
+// *bedrockruntime.Client, and can be replaced by a fake in tests.
+type Invoker interface {
+	InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+	InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error)
+}
+
+var _ Invoker = (*bedrockruntime.Client)(nil)
+
+// Client invokes a Claude 3 model on Amazon Bedrock.
+type Client struct {
+	brc     Invoker
+	modelID string
+
+	// Recorder, if set, receives the raw bytes of every streamed chunk, one per line.
+	// The recording can be played back with a StreamReplayer.
+	Recorder io.Writer
+
+	// OnComplete, if set, is called once a streamed response has ended successfully, with the
+	// complete response including the stop reason and token usage.
+	OnComplete StreamingCompletionHandler
+
+	// Headers are added to every request sent to Bedrock, e.g. X-Amzn-Trace-Id to correlate
+	// the invocations with application logs.
+	Headers map[string]string
+
+	// ResponseFilter, if set, is applied to the text of each response before it is returned, e.g.
+	// to redact secrets. For streamed responses, it's applied to the assembled response returned by
+	// InvokeStream and passed to OnComplete. The deltas passed to the handler, and recorded, are
+	// not filtered, as they arrive before there is any text to filter.
+	ResponseFilter func(string) string
+
+	// ContentType is the content type of the request body, DefaultContentType if it's empty
+	ContentType string
+	// Accept, if set, is sent as the Accept header to ask for a response content type
+	Accept string
+
+	// Limiter, if set, paces the requests, e.g. to stay within the Bedrock quotas in scripted runs
+	Limiter *RateLimiter
+
+	// HTTPClient fetches the images given as urls, e.g. to AskAboutImage. If it's nil, one created
+	// by NewHTTPClient with DefaultFetchTimeout is used
+	HTTPClient *http.Client
+}
+
+func NewClient(brc Invoker, modelID string) *Client {
+	return &Client{brc: brc, modelID: modelID}
+}
+
+// ModelID returns the id of the model invoked by the client.
+func (c *Client) ModelID() string {
+	return c.modelID
+}
+
+// WithModel returns a copy of the client that invokes modelID instead.
+func (c *Client) WithModel(modelID string) *Client {
+	client := *c
+	client.modelID = modelID
+	return &client
+}
+
+// DefaultAnthropicVersion is the anthropic_version expected by Claude 3 models on Bedrock.
+const DefaultAnthropicVersion = "bedrock-2023-05-31"
+
+const defaultMaxTokens = 1024
+
+// withDefaults returns req with DefaultAnthropicVersion and defaultMaxTokens filled in, where
+// they're not set
+func withDefaults(req Claude3Request) Claude3Request {
+
+	if req.AnthropicVersion == "" {
+		req.AnthropicVersion = DefaultAnthropicVersion
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultMaxTokens
+	}
+
+	return req
+}
+
+// DefaultContentType is the content type of the requests unless Client.ContentType is set.
+const DefaultContentType = "application/json"
+
+const roleUser = "user"
+const roleAssistant = "assistant"
+
+// Invoke sends req to the model and returns the complete response.
+func (c *Client) Invoke(ctx context.Context, req Claude3Request) (Claude3Response, error) {
+
+	err := validateMessages(req.Messages)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	payloadBytes, err := json.Marshal(req)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	err = c.wait(ctx)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	output, err := c.brc.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		Body:        payloadBytes,
+		ModelId:     aws.String(c.modelID),
+		ContentType: aws.String(c.contentType()),
+		Accept:      c.accept(),
+	}, c.requestOptions()...)
+
+	if err != nil {
+		return Claude3Response{}, classifyError(err)
+	}
+
+	slog.Debug("response payload", "payload", string(output.Body))
+
+	var resp Claude3Response
+	err = json.Unmarshal(output.Body, &resp)
+	resp.Stats.addAttempts(output.ResultMetadata)
+	if err != nil {
+		return resp, err
+	}
+	c.filter(&resp)
+
+	return resp, nil
+}
+
+// InvokeStream sends req to the model and calls handler with each text delta as it arrives.
+func (c *Client) InvokeStream(ctx context.Context, req Claude3Request, handler StreamingOutputHandler) (Claude3Response, error) {
+
+	err := validateMessages(req.Messages)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	payloadBytes, err := json.Marshal(req)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	err = c.wait(ctx)
+	if err != nil {
+		return Claude3Response{}, err
+	}
+
+	start := time.Now()
+	output, err := c.brc.InvokeModelWithResponseStream(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
+		Body:        payloadBytes,
+		ModelId:     aws.String(c.modelID),
+		ContentType: aws.String(c.contentType()),
+		Accept:      c.accept(),
+	}, c.requestOptions()...)
+
+	if err != nil {
+		return Claude3Response{}, classifyError(err)
+	}
+
+	var stream bedrockruntime.ResponseStreamReader = output.GetStream()
+	if c.Recorder != nil {
+		stream = newRecordingStream(stream, c.Recorder)
+	}
+	defer stream.Close()
+
+	streamStart := time.Now()
+	resp, err := ProcessStreamingOutput(stream, handler)
+	resp.Stats.addAttempts(output.ResultMetadata)
+	if resp.Stats.TimeToFirstToken > 0 {
+		// include the time it took for the response to start
+		resp.Stats.TimeToFirstToken += streamStart.Sub(start)
+	}
+	// callers may keep what was generated before an error
+	c.filter(&resp)
+	if err != nil {
+		return resp, err
+	}
+
+	if c.OnComplete != nil {
+		c.OnComplete(ctx, resp)
+	}
+
+	return resp, nil
+}
+
+// InvokeStreamChan is like InvokeStream, but emits each text delta on the returned channel.
+// The channel is closed once the response is complete. A terminal error, if any, is sent on
+// the error channel, which is closed right after.
+func (c *Client) InvokeStreamChan(ctx context.Context, req Claude3Request) (<-chan string, <-chan error) {
+
+	parts := make(chan string)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(parts)
+
+		_, err := c.InvokeStream(ctx, req, func(_ context.Context, part []byte) error {
+			select {
+			case parts <- string(part):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return parts, errs
+}
+
+func (c *Client) contentType() string {
+
+	if c.ContentType == "" {
+		return DefaultContentType
+	}
+
+	return c.ContentType
+}
+
+func (c *Client) accept() *string {
+
+	if c.Accept == "" {
+		return nil
+	}
+
+	return aws.String(c.Accept)
+}
+
+// wait blocks until the Limiter, if any, lets a request through
+func (c *Client) wait(ctx context.Context) error {
+
+	if c.Limiter == nil {
+		return nil
+	}
+
+	return c.Limiter.Wait(ctx)
+}
+
+// filter applies ResponseFilter to the text blocks of resp
+func (c *Client) filter(resp *Claude3Response) {
+
+	if c.ResponseFilter == nil {
+		return
+	}
+
+	for i, content := range resp.ResponseContent {
+		if content.Type == contentTypeText {
+			resp.ResponseContent[i].Text = c.ResponseFilter(content.Text)
+		}
+	}
+}
+
+func (c *Client) requestOptions() []func(*bedrockruntime.Options) {
+
+	if len(c.Headers) == 0 {
+		return nil
+	}
+
+	return []func(*bedrockruntime.Options){func(o *bedrockruntime.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			// added after the SDK's own middleware, so that e.g. the trace id it takes from the
+			// environment is replaced
+			return stack.Build.Add(middleware.BuildMiddlewareFunc("AddHeaders", func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+				if req, ok := in.Request.(*smithyhttp.Request); ok {
+					for name, value := range c.Headers {
+						req.Header.Set(name, value)
+					}
+				}
+				return next.HandleBuild(ctx, in)
+			}), middleware.After)
+		})
+	}}
+}
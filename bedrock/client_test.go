@@ -0,0 +1,317 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// fakeInvoker answers each request with the text of its last message. Streamed responses
+// are sent word by word, as an event stream that is decoded by a real bedrockruntime.Client.
+type fakeInvoker struct {
+	// err, if set, is returned by InvokeModelWithResponseStream instead of a response
+	err error
+}
+
+func (fakeInvoker) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	var req Claude3Request
+	err := json.Unmarshal(params.Body, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(Claude3Response{
+		Role:            "assistant",
+		ResponseContent: []ResponseContent{{Type: contentTypeText, Text: req.Messages[len(req.Messages)-1].Content[0].Text}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bedrockruntime.InvokeModelOutput{Body: body}, nil
+}
+
+func (f fakeInvoker) InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var req Claude3Request
+	err := json.Unmarshal(params.Body, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := eventStream(fakeChunks(req.Messages[len(req.Messages)-1].Content[0].Text)...)
+	if err != nil {
+		return nil, err
+	}
+
+	brc := bedrockruntime.New(bedrockruntime.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		HTTPClient: doFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/vnd.amazon.eventstream"}},
+				Body:       io.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	})
+
+	return brc.InvokeModelWithResponseStream(ctx, params, optFns...)
+}
+
+type doFunc func(*http.Request) (*http.Response, error)
+
+func (f doFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// fakeChunks returns the chunks of a streamed response with text, sent a word at a time
+func fakeChunks(text string) []PartialResponse {
+
+	chunks := []PartialResponse{
+		{Type: partialResponseTypeMessageStart, Message: PartialResponseMessage{ID: "msg_01", Model: "fake-model", Usage: PartialResponseUsage{InputTokens: 10}}},
+		{Type: partialResponseTypeContentBlockStart, ContentBlock: ContentBlock{Type: contentTypeText}},
+	}
+	words := strings.SplitAfter(text, " ")
+	for _, word := range words {
+		chunks = append(chunks, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: word}})
+	}
+
+	return append(chunks,
+		PartialResponse{Type: partialResponseTypeContentBlockStop},
+		PartialResponse{Type: partialResponseTypeMessageDelta, Delta: Delta{StopReason: "end_turn"}, Usage: PartialResponseUsage{OutputTokens: len(words)}},
+		PartialResponse{Type: partialResponseTypeMessageStop},
+	)
+}
+
+// eventStream encodes chunks the way Bedrock sends them: as chunk events with a base64 encoded payload
+func eventStream(chunks ...PartialResponse) ([]byte, error) {
+
+	var body bytes.Buffer
+	encoder := eventstream.NewEncoder()
+
+	for _, chunk := range chunks {
+		chunkBytes, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(map[string][]byte{"bytes": chunkBytes})
+		if err != nil {
+			return nil, err
+		}
+
+		err = encoder.Encode(&body, eventstream.Message{
+			Headers: eventstream.Headers{
+				{Name: ":message-type", Value: eventstream.StringValue("event")},
+				{Name: ":event-type", Value: eventstream.StringValue("chunk")},
+				{Name: ":content-type", Value: eventstream.StringValue("application/json")},
+			},
+			Payload: payload,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return body.Bytes(), nil
+}
+
+func streamRequest(text string) Claude3Request {
+	return Claude3Request{
+		AnthropicVersion: "bedrock-2023-05-31",
+		MaxTokens:        1024,
+		Messages:         []Message{{Role: "user", Content: []Content{{Type: contentTypeText, Text: text}}}},
+	}
+}
+
+// run with -race to check that a Client can be shared between goroutines
+func TestClientConcurrentInvoke(t *testing.T) {
+
+	client := NewClient(fakeInvoker{}, "model")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			question := fmt.Sprintf("question %d", i)
+			resp, err := client.Invoke(context.Background(), Claude3Request{
+				AnthropicVersion: DefaultAnthropicVersion,
+				MaxTokens:        defaultMaxTokens,
+				Messages:         []Message{{Role: roleUser, Content: []Content{{Type: contentTypeText, Text: question}}}},
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Text() != question {
+				t.Errorf("got %q, want %q", resp.Text(), question)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClientInvokeStream(t *testing.T) {
+
+	client := NewClient(fakeInvoker{}, "model")
+
+	var recording bytes.Buffer
+	client.Recorder = &recording
+
+	var completed []Claude3Response
+	client.OnComplete = func(_ context.Context, resp Claude3Response) {
+		completed = append(completed, resp)
+	}
+
+	var parts []string
+	resp, err := client.InvokeStream(context.Background(), streamRequest("the quick brown fox"), func(_ context.Context, part []byte) error {
+		parts = append(parts, string(part))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"the ", "quick ", "brown ", "fox"}; strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("deltas = %q, want %q", parts, want)
+	}
+	if resp.Text() != "the quick brown fox" || resp.ID != "msg_01" || resp.Model != "fake-model" || resp.StopReason != "end_turn" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if resp.Usage.InputTokens != 10 || resp.Usage.OutputTokens != 4 {
+		t.Errorf("usage = %+v, want 10 input and 4 output tokens", resp.Usage)
+	}
+	if resp.Stats.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", resp.Stats.Attempts)
+	}
+	if resp.Stats.TimeToFirstToken <= 0 {
+		t.Errorf("time to first token = %s, want more than 0", resp.Stats.TimeToFirstToken)
+	}
+
+	if len(completed) != 1 || completed[0].Text() != resp.Text() {
+		t.Errorf("OnComplete called with %+v, want the response once", completed)
+	}
+
+	// one line per chunk and the end of the response, which plays back to the same response
+	if lines := strings.Count(recording.String(), "\n"); lines != len(fakeChunks("the quick brown fox"))+1 {
+		t.Errorf("recorded %d lines, want %d", lines, len(fakeChunks("the quick brown fox"))+1)
+	}
+	replayed, err := ProcessStreamingOutput(NewStreamReplayer(&recording).Next(), func(context.Context, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Text() != resp.Text() {
+		t.Errorf("replayed %q, want %q", replayed.Text(), resp.Text())
+	}
+}
+
+func TestClientInvokeStreamError(t *testing.T) {
+
+	client := NewClient(fakeInvoker{err: errors.New("connection refused")}, "model")
+	client.OnComplete = func(context.Context, Claude3Response) {
+		t.Error("OnComplete called for a failed request")
+	}
+
+	_, err := client.InvokeStream(context.Background(), streamRequest("hello"), func(context.Context, []byte) error {
+		t.Error("handler called for a failed request")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestClientInvokeStreamChan(t *testing.T) {
+
+	client := NewClient(fakeInvoker{}, "model")
+
+	parts, errs := client.InvokeStreamChan(context.Background(), streamRequest("one two three"))
+
+	var text strings.Builder
+	for part := range parts {
+		text.WriteString(part)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != "one two three" {
+		t.Errorf("got %q, want %q", text.String(), "one two three")
+	}
+
+	parts, errs = NewClient(fakeInvoker{err: errors.New("connection refused")}, "model").InvokeStreamChan(context.Background(), streamRequest("hello"))
+	for part := range parts {
+		t.Errorf("unexpected delta %q", part)
+	}
+	if err := <-errs; err == nil {
+		t.Error("expected an error on the error channel")
+	}
+}
+
+func TestClientResponseFilter(t *testing.T) {
+
+	client := NewClient(fakeInvoker{}, "model")
+	client.ResponseFilter = func(text string) string {
+		return strings.ReplaceAll(text, "hunter2", "[redacted]")
+	}
+
+	resp, err := client.Invoke(context.Background(), Claude3Request{
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        defaultMaxTokens,
+		Messages:         []Message{{Role: roleUser, Content: []Content{{Type: contentTypeText, Text: "the password is hunter2"}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := resp.Text(), "the password is [redacted]"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
+
+func TestClientResponseFilterStream(t *testing.T) {
+
+	client := NewClient(fakeInvoker{}, "model")
+	client.ResponseFilter = func(text string) string {
+		return strings.ReplaceAll(text, "hunter2", "[redacted]")
+	}
+
+	var parts []string
+	var completed Claude3Response
+	client.OnComplete = func(_ context.Context, resp Claude3Response) {
+		completed = resp
+	}
+
+	resp, err := client.InvokeStream(context.Background(), streamRequest("hunter2 is the password"), func(_ context.Context, part []byte) error {
+		parts = append(parts, string(part))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[redacted] is the password"; resp.Text() != want {
+		t.Errorf("text = %q, want %q", resp.Text(), want)
+	}
+	if completed.Text() != resp.Text() {
+		t.Errorf("completed text = %q, want %q", completed.Text(), resp.Text())
+	}
+	// the deltas are passed on as they arrive
+	if got := strings.Join(parts, ""); got != "hunter2 is the password" {
+		t.Errorf("deltas = %q", got)
+	}
+}
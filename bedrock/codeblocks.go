@@ -0,0 +1,48 @@
+package bedrock
+
+import "strings"
+
+const codeFence = "```"
+
+// CodeBlock is a fenced code block in a response.
+type CodeBlock struct {
+	// Language is the info string after the opening fence, e.g. "go". It's empty if none was given.
+	Language string
+	Body     string
+}
+
+// ExtractCodeBlocks returns the code blocks fenced with triple backticks in text, in order.
+// A block that isn't closed (e.g. because the response was cut off) runs to the end of the text.
+func ExtractCodeBlocks(text string) []CodeBlock {
+
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var body []string
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if current == nil {
+			if strings.HasPrefix(trimmed, codeFence) {
+				current = &CodeBlock{Language: strings.TrimSpace(strings.TrimPrefix(trimmed, codeFence))}
+				body = nil
+			}
+			continue
+		}
+
+		if trimmed == codeFence {
+			current.Body = strings.Join(body, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		body = append(body, line)
+	}
+
+	if current != nil {
+		current.Body = strings.Join(body, "\n")
+		blocks = append(blocks, *current)
+	}
+
+	return blocks
+}
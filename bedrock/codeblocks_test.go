@@ -0,0 +1,32 @@
+package bedrock
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want []CodeBlock
+	}{
+		{"no blocks", "just text", nil},
+		{"single", "here you go:\n```go\nfmt.Println(1)\n```\ndone", []CodeBlock{{Language: "go", Body: "fmt.Println(1)"}}},
+		{"no language", "```\nls -l\n```", []CodeBlock{{Body: "ls -l"}}},
+		{"multiple", "```sh\nmake\n```\nthen\n```python\nprint(1)\n\nprint(2)\n```", []CodeBlock{
+			{Language: "sh", Body: "make"},
+			{Language: "python", Body: "print(1)\n\nprint(2)"},
+		}},
+		{"indented body", "```yaml\na:\n  b: 1\n```", []CodeBlock{{Language: "yaml", Body: "a:\n  b: 1"}}},
+		{"unclosed", "```go\nfunc main() {", []CodeBlock{{Language: "go", Body: "func main() {"}}},
+	}
+
+	for _, test := range tests {
+		got := ExtractCodeBlocks(test.text)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// CredentialsHelp explains how to set up the AWS credentials, for errors caused by them.
+const CredentialsHelp = "configure them with 'aws configure', set AWS_PROFILE (or -profile) or run 'aws sso login'"
+
+// ErrTemperatureAndTopP is returned by Config.Validate when both the temperature and top_p are set.
+var ErrTemperatureAndTopP = errors.New("temperature and top_p are both set. Anthropic recommends adjusting only one " +
+	"of them, as they both change how tokens are sampled and combining them makes the results hard to predict. " +
+	"use -allow-temperature-and-top-p to set both anyway")
+
+// Config holds the settings for connecting to Bedrock and the defaults of each request.
+// The zero value of an optional setting means that the AWS or model default is used.
+type Config struct {
+	ModelID string
+	// Region is resolved with ResolveRegion, so AWS_REGION and DefaultRegion apply when it's empty
+	Region  string
+	Profile string
+	// Endpoint replaces the default Bedrock endpoint, e.g. to use a local mock
+	Endpoint string
+	// TraceID is sent as the X-Amzn-Trace-Id header of each request
+	TraceID string
+	// ContentType and Accept are the content type of the requests and the one asked for in the
+	// responses. They rarely need to change, e.g. for debugging
+	ContentType string
+	Accept      string
+
+	AnthropicVersion string
+	MaxTokens        int
+	Temperature      float64
+	TopP             float64
+
+	// RequestsPerMinute limits the rate of requests sent by the client. 0 means no limit
+	RequestsPerMinute int
+
+	// AllowTemperatureAndTopP lets Validate accept both Temperature and TopP, which Anthropic
+	// recommends against
+	AllowTemperatureAndTopP bool
+}
+
+// DefaultConfig returns a Config that invokes modelID with the default request settings.
+func DefaultConfig(modelID string) Config {
+	return Config{
+		ModelID:          modelID,
+		ContentType:      DefaultContentType,
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        defaultMaxTokens,
+	}
+}
+
+// RegisterFlags defines a flag for each setting in fs, with the current values as the defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.ModelID, "model", c.ModelID, "id of the model to invoke. inference profile ids and ARNs work as well")
+	fs.StringVar(&c.Region, "region", c.Region, "AWS region to use. takes precedence over the AWS_REGION environment variable")
+	fs.StringVar(&c.Profile, "profile", c.Profile, "named AWS profile to use from the shared config and credentials files")
+	fs.StringVar(&c.Endpoint, "endpoint", c.Endpoint, "custom Bedrock runtime endpoint url, e.g. http://localhost:8080 for a local mock")
+	fs.StringVar(&c.TraceID, "trace-id", c.TraceID, "value of the X-Amzn-Trace-Id header sent with each request, to correlate them with other logs")
+	fs.StringVar(&c.ContentType, "content-type", c.ContentType, "content type of the requests")
+	fs.StringVar(&c.Accept, "accept", c.Accept, "value of the Accept header of the requests. by default it's not sent")
+	fs.StringVar(&c.AnthropicVersion, "anthropic-version", c.AnthropicVersion, "anthropic_version to send with each request")
+	fs.IntVar(&c.MaxTokens, "max-tokens", c.MaxTokens, "maximum number of tokens to generate per response")
+	fs.Float64Var(&c.Temperature, "temperature", c.Temperature, "randomness of the responses, from 0 to 1. 0 means the model default")
+	fs.Float64Var(&c.TopP, "top-p", c.TopP, "nucleus sampling cutoff, from 0 to 1. 0 means the model default")
+	fs.IntVar(&c.RequestsPerMinute, "rpm", c.RequestsPerMinute, "maximum number of requests to send per minute, to avoid throttling in scripted runs. 0 means no limit")
+	fs.BoolVar(&c.AllowTemperatureAndTopP, "allow-temperature-and-top-p", c.AllowTemperatureAndTopP, "allow -temperature and -top-p to be set together")
+}
+
+// Validate checks the settings, and their combinations, before anything is sent.
+func (c Config) Validate() error {
+
+	err := ValidateModelID(c.ModelID)
+	if err != nil {
+		return err
+	}
+
+	if c.AnthropicVersion == "" {
+		return errors.New("the anthropic version can't be empty")
+	}
+	if c.MaxTokens <= 0 {
+		return fmt.Errorf("max tokens must be positive, got %d", c.MaxTokens)
+	}
+	if c.Temperature < 0 || c.Temperature > 1 {
+		return fmt.Errorf("temperature must be between 0 and 1, got %g", c.Temperature)
+	}
+	if c.TopP < 0 || c.TopP > 1 {
+		return fmt.Errorf("top_p must be between 0 and 1, got %g", c.TopP)
+	}
+	if c.RequestsPerMinute < 0 {
+		return fmt.Errorf("requests per minute can't be negative, got %d", c.RequestsPerMinute)
+	}
+	if c.Temperature > 0 && c.TopP > 0 && !c.AllowTemperatureAndTopP {
+		return ErrTemperatureAndTopP
+	}
+
+	return nil
+}
+
+// Request returns a request with the request settings of the config, and no messages.
+func (c Config) Request() Claude3Request {
+	return Claude3Request{
+		AnthropicVersion: c.AnthropicVersion,
+		MaxTokens:        c.MaxTokens,
+		Temperature:      c.Temperature,
+		TopP:             c.TopP,
+	}
+}
+
+// NewClientFromConfig creates a Client for the model, region and credentials in cfg. It warns if
+// the model isn't known to be available in the region, and fails early if there are no valid AWS
+// credentials. Neither is checked with a custom endpoint, which may well serve any model: without
+// credentials, e.g. for a local mock, the requests are sent unsigned.
+func NewClientFromConfig(ctx context.Context, cfg Config) (*Client, error) {
+
+	region := ResolveRegion(cfg.Region)
+
+	if cfg.Endpoint == "" {
+		err := CheckModelRegion(cfg.ModelID, region)
+		if err != nil {
+			slog.Warn("the model may not be available", "error", err)
+		}
+	}
+
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+	if cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	_, err = awsCfg.Credentials.Retrieve(ctx)
+	switch {
+	case err != nil && cfg.Endpoint != "":
+		slog.Debug("no valid AWS credentials found, sending unsigned requests to the custom endpoint", "error", err)
+		awsCfg.Credentials = aws.AnonymousCredentials{}
+	case err != nil:
+		return nil, fmt.Errorf("no valid AWS credentials found. %s: %w", CredentialsHelp, err)
+	}
+
+	brc := bedrockruntime.NewFromConfig(awsCfg, func(o *bedrockruntime.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	client := NewClient(brc, cfg.ModelID)
+	client.ContentType = cfg.ContentType
+	client.Accept = cfg.Accept
+	if cfg.TraceID != "" {
+		client.Headers = map[string]string{"X-Amzn-Trace-Id": cfg.TraceID}
+	}
+	if cfg.RequestsPerMinute > 0 {
+		client.Limiter = NewRateLimiter(cfg.RequestsPerMinute)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,47 @@
+package bedrock
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+
+	valid := DefaultConfig("anthropic.claude-3-haiku-20240307-v1:0")
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"invalid model", func(c *Config) { c.ModelID = "amazon.titan-text-express-v1" }, true},
+		{"no anthropic version", func(c *Config) { c.AnthropicVersion = "" }, true},
+		{"zero max tokens", func(c *Config) { c.MaxTokens = 0 }, true},
+		{"temperature", func(c *Config) { c.Temperature = 0.5 }, false},
+		{"temperature out of range", func(c *Config) { c.Temperature = 1.5 }, true},
+		{"top_p out of range", func(c *Config) { c.TopP = -0.1 }, true},
+		{"negative rpm", func(c *Config) { c.RequestsPerMinute = -1 }, true},
+		{"temperature and top_p", func(c *Config) { c.Temperature, c.TopP = 0.5, 0.9 }, true},
+		{"temperature and top_p allowed", func(c *Config) {
+			c.Temperature, c.TopP = 0.5, 0.9
+			c.AllowTemperatureAndTopP = true
+		}, false},
+	}
+
+	for _, test := range tests {
+		cfg := valid
+		test.modify(&cfg)
+
+		err := cfg.Validate()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+
+	cfg := valid
+	cfg.Temperature, cfg.TopP = 0.5, 0.9
+	if err := cfg.Validate(); !errors.Is(err, ErrTemperatureAndTopP) {
+		t.Errorf("got %v, want ErrTemperatureAndTopP", err)
+	}
+}
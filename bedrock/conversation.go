@@ -0,0 +1,93 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// ErrEmptyResponse is returned by Conversation and AskAboutImage when the response has no text.
+var ErrEmptyResponse = errors.New("model returned an empty response")
+
+// Conversation keeps track of the messages exchanged with the model. The content of the next
+// user message is added with the Append methods, and Send sends it along with the conversation
+// so far, adding the reply to the conversation.
+type Conversation struct {
+	// Request holds the settings sent with every message, and the conversation so far
+	Request Claude3Request
+
+	client  *Client
+	pending []Content
+}
+
+// NewConversation returns a conversation with client that continues from the messages in req, if any.
+func NewConversation(client *Client, req Claude3Request) *Conversation {
+	return &Conversation{Request: req, client: client}
+}
+
+// AppendUser adds text to the next user message.
+func (c *Conversation) AppendUser(text string) {
+	c.pending = append(c.pending, TextContent(text))
+}
+
+// AppendImage adds the image at imagePath to the next user message. urls are fetched with the
+// HTTPClient of the conversation's client.
+func (c *Conversation) AppendImage(imagePath string) error {
+
+	var httpClient *http.Client
+	if c.client != nil {
+		httpClient = c.client.HTTPClient
+	}
+
+	imageContent, err := NewImageContent(httpClient, imagePath)
+	if err != nil {
+		return err
+	}
+	c.pending = append(c.pending, imageContent)
+
+	return nil
+}
+
+// AppendContent adds content blocks to the next user message.
+func (c *Conversation) AppendContent(content ...Content) {
+	c.pending = append(c.pending, content...)
+}
+
+// Send sends the next user message and returns the text of the reply.
+func (c *Conversation) Send(ctx context.Context) (string, error) {
+
+	resp, err := c.SendWith(ctx, c.client.Invoke)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Text(), nil
+}
+
+// SendWith is like Send, but sends the request with send, e.g. to stream the response. The
+// user message and the reply are only added to the conversation if there is a reply, so that
+// the roles keep alternating. The user message is cleared either way.
+func (c *Conversation) SendWith(ctx context.Context, send func(context.Context, Claude3Request) (Claude3Response, error)) (Claude3Response, error) {
+
+	if len(c.pending) == 0 {
+		return Claude3Response{}, errors.New("there is no message to send")
+	}
+
+	req := c.Request
+	// clipped, so that a failed request doesn't leave the user message in the backing array
+	req.Messages = append(slices.Clip(c.Request.Messages), Message{Role: roleUser, Content: c.pending})
+	c.pending = nil
+
+	resp, err := send(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Text() == "" {
+		return resp, ErrEmptyResponse
+	}
+
+	c.Request.Messages = append(req.Messages, AssistantText(resp.Text()))
+
+	return resp, nil
+}
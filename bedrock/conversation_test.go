@@ -0,0 +1,122 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConversation(t *testing.T) {
+
+	// fakeInvoker answers with the text of the last message
+	conv := NewConversation(NewClient(fakeInvoker{}, "model"), Claude3Request{
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        defaultMaxTokens,
+	})
+
+	for _, text := range []string{"first", "second"} {
+		conv.AppendUser(text)
+		answer, err := conv.Send(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if answer != text {
+			t.Errorf("got %q, want %q", answer, text)
+		}
+	}
+
+	if err := validateMessages(conv.Request.Messages); err != nil {
+		t.Error(err)
+	}
+	if len(conv.Request.Messages) != 4 {
+		t.Errorf("got %d messages, want 4", len(conv.Request.Messages))
+	}
+}
+
+func TestConversationSendFailure(t *testing.T) {
+
+	conv := NewConversation(nil, Claude3Request{Messages: []Message{UserText("hi"), AssistantText("hello")}})
+
+	conv.AppendUser("are you there?")
+	_, err := conv.SendWith(context.Background(), func(ctx context.Context, req Claude3Request) (Claude3Response, error) {
+		return Claude3Response{}, errors.New("throttled")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the user message without a reply is not kept
+	if len(conv.Request.Messages) != 2 {
+		t.Errorf("got %d messages, want 2", len(conv.Request.Messages))
+	}
+
+	conv.AppendUser("still there?")
+	_, err = conv.SendWith(context.Background(), func(ctx context.Context, req Claude3Request) (Claude3Response, error) {
+		return Claude3Response{}, nil
+	})
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("got %v, want ErrEmptyResponse", err)
+	}
+	if len(conv.Request.Messages) != 2 {
+		t.Errorf("got %d messages, want 2", len(conv.Request.Messages))
+	}
+
+	_, err = conv.SendWith(context.Background(), nil)
+	if err == nil {
+		t.Error("expected an error when there is no message to send")
+	}
+}
+
+func TestConversationSummarize(t *testing.T) {
+
+	seed := []Message{UserText("you are a tutor"), AssistantText("ok")}
+	conv := NewConversation(nil, Claude3Request{
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        defaultMaxTokens,
+		Messages: append(seed,
+			UserText("what's 2+2?"), AssistantText("4"),
+			UserText("and 3+3?"), AssistantText("6"),
+			UserText("and 4+4?"), AssistantText("8"),
+		),
+	})
+
+	// fakeInvoker answers with the prompt, which includes the transcript
+	_, err := conv.Summarize(context.Background(), NewClient(fakeInvoker{}, "model"), 2, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := conv.Request.Messages
+	if len(messages) != 6 {
+		t.Fatalf("got %d messages, want 6", len(messages))
+	}
+	if err := validateMessages(messages); err != nil {
+		t.Error(err)
+	}
+	summary := messages[2].Content[0].Text
+	if !strings.Contains(summary, "User: what's 2+2?") || !strings.Contains(summary, "Assistant: 6") {
+		t.Errorf("summary %q doesn't include the transcript", summary)
+	}
+	if messages[4].Content[0].Text != "and 4+4?" {
+		t.Errorf("the latest turn should be kept, got %q", messages[4].Content[0].Text)
+	}
+
+	_, err = conv.Summarize(context.Background(), NewClient(fakeInvoker{}, "model"), 1, 3)
+	if err == nil {
+		t.Error("expected an error for messages that don't start with a user message")
+	}
+
+	// the settings of the conversation are not sent along with the summary request
+	conv.Request.SystemPrompt = "be terse"
+	conv.Request.Temperature = 0.2
+	conv.Request.StopSequences = []string{"END"}
+	var sent Claude3Request
+	_, err = conv.Summarize(context.Background(), NewClient(captureInvoker{answer: "a summary", req: &sent}, "model"), 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.SystemPrompt != "" || sent.Temperature != 0 || sent.StopSequences != nil || sent.MaxTokens != defaultMaxTokens || len(sent.Messages) != 1 {
+		t.Errorf("summary request = %+v", sent)
+	}
+}
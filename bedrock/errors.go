@@ -0,0 +1,85 @@
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+// The errors returned by Client wrap one of these, depending on what went wrong, so that
+// callers can tell them apart with errors.Is. The original error is wrapped as well.
+var (
+	// ErrThrottled means that a quota was exceeded. The request can be sent again later.
+	ErrThrottled = errors.New("throttled")
+	// ErrValidation means that the request is invalid, e.g. too long for the model, and has to be fixed.
+	ErrValidation = errors.New("invalid request")
+	// ErrAuth means that the credentials are missing, invalid or lack permission, e.g. for the model.
+	ErrAuth = errors.New("not authorized")
+	// ErrServer means that Bedrock or the model failed to handle a valid request.
+	ErrServer = errors.New("server error")
+	// ErrStream means that a streamed response failed after it started.
+	ErrStream = errors.New("stream failed")
+)
+
+// classifyError wraps err with the category it belongs to, if it's known
+func classifyError(err error) error {
+
+	var (
+		throttlingErr   *types.ThrottlingException
+		quotaErr        *types.ServiceQuotaExceededException
+		validationErr   *types.ValidationException
+		notFoundErr     *types.ResourceNotFoundException
+		accessDeniedErr *types.AccessDeniedException
+		modelErr        *types.ModelErrorException
+		notReadyErr     *types.ModelNotReadyException
+		timeoutErr      *types.ModelTimeoutException
+		apiErr          smithy.APIError
+	)
+
+	switch {
+	case errors.As(err, &throttlingErr), errors.As(err, &quotaErr):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	case errors.As(err, &validationErr), errors.As(err, &notFoundErr):
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	case errors.As(err, &accessDeniedErr), IsCredentialsError(err):
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	// Bedrock reports these as client faults, but they are failures of the model
+	case errors.As(err, &modelErr), errors.As(err, &notReadyErr), errors.As(err, &timeoutErr):
+		return fmt.Errorf("%w: %w", ErrServer, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer:
+		return fmt.Errorf("%w: %w", ErrServer, err)
+	case errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultClient:
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	return err
+}
+
+// credentialsErrorCodes are the error codes Bedrock returns for missing, invalid or expired credentials
+var credentialsErrorCodes = map[string]bool{
+	"UnrecognizedClientException": true,
+	"InvalidSignatureException":   true,
+	"ExpiredTokenException":       true,
+	"ExpiredToken":                true,
+	"InvalidClientTokenId":        true,
+}
+
+// IsCredentialsError reports whether err was caused by AWS credentials that could not be
+// found (which fails the request before it's sent), or were rejected by Bedrock.
+func IsCredentialsError(err error) bool {
+
+	var signingErr *v4.SigningError
+	if errors.As(err, &signingErr) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return credentialsErrorCodes[apiErr.ErrorCode()]
+	}
+
+	return false
+}
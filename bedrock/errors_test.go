@@ -0,0 +1,72 @@
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestIsCredentialsError(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&v4.SigningError{Err: errors.New("failed to retrieve credentials")}, true},
+		{fmt.Errorf("operation error: %w", &smithy.GenericAPIError{Code: "UnrecognizedClientException"}), true},
+		{&smithy.GenericAPIError{Code: "ExpiredTokenException"}, true},
+		{&smithy.GenericAPIError{Code: "ThrottlingException"}, false},
+		{errors.New("connection refused"), false},
+	}
+
+	for _, test := range tests {
+		if got := IsCredentialsError(test.err); got != test.want {
+			t.Errorf("IsCredentialsError(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&types.ThrottlingException{}, ErrThrottled},
+		{&types.ServiceQuotaExceededException{}, ErrThrottled},
+		{&types.ValidationException{}, ErrValidation},
+		{&types.AccessDeniedException{}, ErrAuth},
+		{&smithy.GenericAPIError{Code: "UnrecognizedClientException"}, ErrAuth},
+		{&types.InternalServerException{}, ErrServer},
+		{&types.ModelTimeoutException{}, ErrServer},
+		{&smithy.GenericAPIError{Code: "SomethingNew", Fault: smithy.FaultServer}, ErrServer},
+	}
+
+	for _, test := range tests {
+		err := classifyError(fmt.Errorf("operation error: %w", test.err))
+		if !errors.Is(err, test.want) {
+			t.Errorf("classifyError(%T) = %v, want %v", test.err, err, test.want)
+		}
+		// the original error is still there
+		if !errors.Is(err, test.err) {
+			t.Errorf("classifyError(%T) lost the original error", test.err)
+		}
+	}
+
+	plain := errors.New("connection refused")
+	if err := classifyError(plain); err != plain {
+		t.Errorf("got %v, want the error unchanged", err)
+	}
+}
+
+func TestStreamErrorClassified(t *testing.T) {
+
+	err := streamError(&types.ThrottlingException{})
+	if !errors.Is(err, ErrStream) || !errors.Is(err, ErrThrottled) {
+		t.Errorf("got %v, want both ErrStream and ErrThrottled", err)
+	}
+}
@@ -0,0 +1,30 @@
+package bedrock
+
+import (
+	"context"
+	"io"
+)
+
+// MultiHandler returns a handler that passes each delta on to all of handlers, in order. The
+// first error stops it, and is returned so that the stream is aborted.
+func MultiHandler(handlers ...StreamingOutputHandler) StreamingOutputHandler {
+
+	return func(ctx context.Context, part []byte) error {
+		for _, handler := range handlers {
+			err := handler(ctx, part)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WriterHandler returns a handler that writes each delta to w, e.g. a log file.
+func WriterHandler(w io.Writer) StreamingOutputHandler {
+
+	return func(_ context.Context, part []byte) error {
+		_, err := w.Write(part)
+		return err
+	}
+}
@@ -0,0 +1,47 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMultiHandler(t *testing.T) {
+
+	var first, second strings.Builder
+	handler := MultiHandler(WriterHandler(&first), WriterHandler(&second))
+
+	for _, part := range []string{"Hello", ", world"} {
+		err := handler(context.Background(), []byte(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if first.String() != "Hello, world" || second.String() != "Hello, world" {
+		t.Errorf("got %q and %q, want both to be %q", first.String(), second.String(), "Hello, world")
+	}
+}
+
+func TestMultiHandlerError(t *testing.T) {
+
+	errSink := errors.New("disk full")
+	called := false
+
+	handler := MultiHandler(
+		func(ctx context.Context, part []byte) error { return errSink },
+		func(ctx context.Context, part []byte) error {
+			called = true
+			return nil
+		},
+	)
+
+	err := handler(context.Background(), []byte("hi"))
+	if !errors.Is(err, errSink) {
+		t.Errorf("got %v, want %v", err, errSink)
+	}
+	if called {
+		t.Error("the handlers after a failed one should not be called")
+	}
+}
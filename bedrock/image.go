@@ -0,0 +1,205 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// imageMediaTypes maps the extensions of the image formats supported by Claude to their media type
+var imageMediaTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// ImageMediaType returns the media type of the image named name. It's based on the file extension
+// if it's a known one, otherwise it's detected from the image contents.
+func ImageMediaType(name string, imageBytes []byte) string {
+
+	if mediaType, ok := imageMediaTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return mediaType
+	}
+
+	return http.DetectContentType(imageBytes)
+}
+
+// IsImageFile reports whether name has the extension of an image format supported by Claude.
+func IsImageFile(name string) bool {
+	_, ok := imageMediaTypes[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// NewImageContent reads the image at source and returns it as a base64 encoded content block.
+// source is a local path or http(s) url, read with ReadSource using httpClient, "-" for stdin, or
+// a data:<media type>;base64,<data> URI. The media type is determined by ImageMediaType unless
+// it's given as a :image/<type> suffix of source, e.g. photo.bin:image/png. The image is checked
+// to be in a format that Claude accepts, so that e.g. truncated or animated images are rejected
+// before they are sent.
+func NewImageContent(httpClient *http.Client, source string) (Content, error) {
+
+	if strings.HasPrefix(source, "data:") {
+		return readDataURI(source)
+	}
+
+	source, mediaType := SplitMediaType(source)
+
+	var imageBytes []byte
+	var err error
+
+	if source == "-" {
+		imageBytes, err = io.ReadAll(os.Stdin)
+	} else {
+		imageBytes, err = ReadSource(httpClient, source, "image/")
+	}
+	if err != nil {
+		return Content{}, err
+	}
+
+	if mediaType == "" {
+		mediaType = ImageMediaType(source, imageBytes)
+	}
+
+	err = validateImage(imageBytes, mediaType)
+	if err != nil {
+		return Content{}, fmt.Errorf("not a valid image: %s: %w", source, err)
+	}
+
+	return ImageContent(mediaType, base64.StdEncoding.EncodeToString(imageBytes)), nil
+}
+
+// SplitMediaType splits an explicit media type off an image source, e.g. photo.bin:image/png
+// returns photo.bin and image/png. The media type is empty if there is none.
+func SplitMediaType(source string) (string, string) {
+
+	i := strings.LastIndex(source, ":image/")
+	if i < 0 {
+		return source, ""
+	}
+
+	return source[:i], source[i+1:]
+}
+
+// readDataURI returns the image in a data:<media type>;base64,<data> URI as a content block
+func readDataURI(uri string) (Content, error) {
+
+	header, data, found := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return Content{}, errors.New("invalid data URI, only base64 encoded ones (data:<media type>;base64,<data>) are supported")
+	}
+	mediaType, _, _ := strings.Cut(header, ";")
+
+	imageBytes, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Content{}, fmt.Errorf("invalid base64 data in data URI: %w", err)
+	}
+
+	err = validateImage(imageBytes, mediaType)
+	if err != nil {
+		return Content{}, fmt.Errorf("not a valid image: data URI: %w", err)
+	}
+
+	return ImageContent(mediaType, data), nil
+}
+
+// validateImage checks that the image is in a format that Claude accepts
+func validateImage(imageBytes []byte, mediaType string) error {
+
+	switch mediaType {
+	case "image/jpeg":
+		// decode the whole image, so that truncated files are caught as well
+		_, err := jpeg.Decode(bytes.NewReader(imageBytes))
+		if err != nil {
+			return fmt.Errorf("invalid JPEG: %w", err)
+		}
+		return nil
+	case "image/png":
+		_, err := png.Decode(bytes.NewReader(imageBytes))
+		if err != nil {
+			return fmt.Errorf("invalid PNG: %w", err)
+		}
+		return nil
+	case "image/gif":
+		// Claude only reads the first frame of an animated GIF, and rejects some of them outright
+		g, err := gif.DecodeAll(bytes.NewReader(imageBytes))
+		if err != nil {
+			return fmt.Errorf("invalid GIF: %w", err)
+		}
+		if len(g.Image) > 1 {
+			return fmt.Errorf("animated GIFs are not supported (found %d frames)", len(g.Image))
+		}
+		return nil
+	case "image/webp":
+		return validateWebP(imageBytes)
+	default:
+		return fmt.Errorf("unsupported image type %s, use JPEG, PNG, GIF or WebP", mediaType)
+	}
+}
+
+// validateWebP checks the first chunk of the WebP container (after the 12 byte RIFF header). lossy (VP8)
+// and lossless (VP8L) images are supported, extended (VP8X) ones only if they are not animated
+func validateWebP(imageBytes []byte) error {
+
+	if len(imageBytes) < 16 {
+		return errors.New("invalid WebP: file is too short")
+	}
+
+	switch chunk := string(imageBytes[12:16]); chunk {
+	case "VP8 ", "VP8L":
+		return nil
+	case "VP8X":
+		const animationFlag = 0x02
+		if len(imageBytes) < 21 {
+			return errors.New("invalid WebP: file is too short")
+		}
+		if imageBytes[20]&animationFlag != 0 {
+			return errors.New("animated WebP images are not supported")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported WebP variant %q", chunk)
+	}
+}
+
+// AskAboutImage sends the image at imagePath along with question to the model and returns the answer.
+// The request has the settings of req, e.g. the system prompt, temperature or metadata, with any
+// messages in it replaced. The anthropic version and max tokens default to DefaultAnthropicVersion
+// and 1024 if they're not set.
+func (c *Client) AskAboutImage(ctx context.Context, req Claude3Request, imagePath, question string) (string, error) {
+
+	imageContent, err := NewImageContent(c.HTTPClient, imagePath)
+	if err != nil {
+		return "", err
+	}
+
+	req = withDefaults(req)
+	req.Messages = []Message{
+		{
+			Role:    roleUser,
+			Content: []Content{imageContent, TextContent(question)},
+		},
+	}
+
+	resp, err := c.Invoke(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.Text() == "" {
+		return "", ErrEmptyResponse
+	}
+
+	return resp.Text(), nil
+}
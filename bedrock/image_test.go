@@ -0,0 +1,285 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+var pngBytes = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestImageMediaType(t *testing.T) {
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"photo.jpg", "image/jpeg"},
+		{"photo.jpeg", "image/jpeg"},
+		{"PHOTO.JPG", "image/jpeg"},
+		{"photo.png", "image/png"},
+		{"photo.gif", "image/gif"},
+		{"photo.webp", "image/webp"},
+		// unknown or missing extensions fall back to the contents, which are a PNG
+		{"photo", "image/png"},
+		{"photo.bin", "image/png"},
+		{"-", "image/png"},
+	}
+
+	for _, test := range tests {
+		if got := ImageMediaType(test.name, pngBytes); got != test.want {
+			t.Errorf("ImageMediaType(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewImageContentWithoutExtension(t *testing.T) {
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "photo")
+	err = os.WriteFile(path, buf.Bytes(), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := NewImageContent(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Source.MediaType != "image/png" {
+		t.Errorf("unexpected media type %q", content.Source.MediaType)
+	}
+}
+
+func TestNewImageContentInvalid(t *testing.T) {
+
+	dir := t.TempDir()
+
+	truncated := filepath.Join(dir, "photo.png")
+	err := os.WriteFile(truncated, pngBytes, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not an image, so the detected media type is text/plain
+	text := filepath.Join(dir, "notes")
+	err = os.WriteFile(text, []byte("some notes"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{truncated, text, truncated + ":image/bmp"} {
+		if _, err := NewImageContent(nil, path); err == nil {
+			t.Errorf("expected an error for %s", path)
+		}
+	}
+}
+
+func TestReadDataURI(t *testing.T) {
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	content, err := NewImageContent(nil, "data:image/png;base64,"+data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Source.Data != data || content.Source.MediaType != "image/png" {
+		t.Errorf("unexpected result %q, %q", content.Source.Data, content.Source.MediaType)
+	}
+
+	for _, uri := range []string{
+		"data:image/png," + data,
+		"data:image/png;base64,not base64",
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("not a png")),
+	} {
+		if _, err := NewImageContent(nil, uri); err == nil {
+			t.Errorf("expected an error for %q", uri)
+		}
+	}
+}
+
+func TestSplitMediaType(t *testing.T) {
+
+	tests := []struct {
+		source, wantSource, wantMediaType string
+	}{
+		{"photo.bin:image/png", "photo.bin", "image/png"},
+		{"-:image/jpeg", "-", "image/jpeg"},
+		{"https://example.com/cat.jpg:image/jpeg", "https://example.com/cat.jpg", "image/jpeg"},
+		{"https://example.com/cat.jpg", "https://example.com/cat.jpg", ""},
+		{"photo.png", "photo.png", ""},
+	}
+
+	for _, test := range tests {
+		source, mediaType := SplitMediaType(test.source)
+		if source != test.wantSource || mediaType != test.wantMediaType {
+			t.Errorf("SplitMediaType(%q) = %q, %q, want %q, %q", test.source, source, mediaType, test.wantSource, test.wantMediaType)
+		}
+	}
+}
+
+// webp returns a WebP header whose first chunk is chunk, followed by payload
+func webp(chunk string, payload ...byte) []byte {
+	b := append([]byte("RIFF\x00\x00\x00\x00WEBP"), chunk...)
+	// the chunk size
+	b = append(b, 10, 0, 0, 0)
+	return append(b, payload...)
+}
+
+func TestValidateImage(t *testing.T) {
+
+	frame := func() *image.Paletted {
+		return image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+	}
+	encodeGIF := func(frames int) []byte {
+		g := &gif.GIF{}
+		for range frames {
+			g.Image = append(g.Image, frame())
+			g.Delay = append(g.Delay, 10)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	var jpegBuf bytes.Buffer
+	err := jpeg.Encode(&jpegBuf, image.NewGray(image.Rect(0, 0, 16, 16)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpegBytes := jpegBuf.Bytes()
+
+	// VP8X flags are the byte right after the chunk size, at offset 20
+	const animationFlag = 0x02
+
+	tests := []struct {
+		name      string
+		image     []byte
+		mediaType string
+		wantErr   string
+	}{
+		{"JPEG", jpegBytes, "image/jpeg", ""},
+		{"truncated JPEG", jpegBytes[:len(jpegBytes)/2], "image/jpeg", "invalid JPEG"},
+		{"still GIF", encodeGIF(1), "image/gif", ""},
+		{"animated GIF", encodeGIF(2), "image/gif", "animated GIFs are not supported (found 2 frames)"},
+		{"lossy WebP", webp("VP8 "), "image/webp", ""},
+		{"lossless WebP", webp("VP8L"), "image/webp", ""},
+		{"still extended WebP", webp("VP8X", 0x10), "image/webp", ""},
+		{"animated extended WebP", webp("VP8X", 0x10|animationFlag), "image/webp", "animated WebP images are not supported"},
+		{"extended WebP without flags", webp("VP8X"), "image/webp", "file is too short"},
+		{"unsupported WebP chunk", webp("ALPH"), "image/webp", `unsupported WebP variant "ALPH"`},
+		{"short WebP", []byte("RIFF\x00\x00\x00\x00WEBP"), "image/webp", "file is too short"},
+		{"unsupported type", jpegBytes, "image/bmp", "unsupported image type image/bmp"},
+	}
+
+	for _, test := range tests {
+		err := validateImage(test.image, test.mediaType)
+
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+// captureInvoker keeps the last request it was sent, and answers it with answer
+type captureInvoker struct {
+	fakeInvoker
+	answer string
+	req    *Claude3Request
+}
+
+func (i captureInvoker) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	err := json.Unmarshal(params.Body, i.req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(Claude3Response{
+		Role:            roleAssistant,
+		ResponseContent: []ResponseContent{{Type: contentTypeText, Text: i.answer}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bedrockruntime.InvokeModelOutput{Body: body}, nil
+}
+
+func TestClientAskAboutImage(t *testing.T) {
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "photo.png")
+	err = os.WriteFile(path, buf.Bytes(), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req Claude3Request
+	client := NewClient(captureInvoker{answer: "a gray square", req: &req}, "model")
+
+	answer, err := client.AskAboutImage(context.Background(), Claude3Request{
+		SystemPrompt: "Be brief.",
+		Temperature:  0.2,
+		Metadata:     &Metadata{UserID: "user-1"},
+	}, path, "What is this?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "a gray square" {
+		t.Errorf("got %q", answer)
+	}
+
+	if req.SystemPrompt != "Be brief." || req.Temperature != 0.2 || req.Metadata == nil || req.Metadata.UserID != "user-1" {
+		t.Errorf("the settings were not sent: %+v", req)
+	}
+	if req.AnthropicVersion != DefaultAnthropicVersion || req.MaxTokens != defaultMaxTokens {
+		t.Errorf("the defaults were not filled in: %q, %d", req.AnthropicVersion, req.MaxTokens)
+	}
+
+	_, err = client.AskAboutImage(context.Background(), Claude3Request{MaxTokens: 4096}, path, "What is this?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.MaxTokens != 4096 {
+		t.Errorf("max tokens = %d, want 4096", req.MaxTokens)
+	}
+
+	client = NewClient(captureInvoker{req: &req}, "model")
+	_, err = client.AskAboutImage(context.Background(), Claude3Request{}, path, "What is this?")
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("got %v, want ErrEmptyResponse", err)
+	}
+}
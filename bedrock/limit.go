@@ -0,0 +1,25 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrOutputLimit is returned by a handler created with LimitOutput once the limit is reached.
+var ErrOutputLimit = errors.New("output limit exceeded")
+
+// LimitOutput returns a handler that passes the deltas on to handler until they add up to more
+// than maxBytes, at which point it returns ErrOutputLimit. ProcessStreamingOutput then stops
+// reading the stream and returns the text so far, with Truncated set.
+func LimitOutput(maxBytes int, handler StreamingOutputHandler) StreamingOutputHandler {
+
+	total := 0
+
+	return func(ctx context.Context, part []byte) error {
+		total += len(part)
+		if total > maxBytes {
+			return ErrOutputLimit
+		}
+		return handler(ctx, part)
+	}
+}
@@ -0,0 +1,33 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadForLog returns req as indented JSON, with the base64 data of images and documents replaced
+// by a placeholder. It is meant for logging only, the result is not a valid payload.
+func PayloadForLog(req Claude3Request) string {
+
+	messages := make([]Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		content := make([]Content, len(msg.Content))
+		for j, c := range msg.Content {
+			if c.Source != nil {
+				source := *c.Source
+				source.Data = fmt.Sprintf("<base64 %d bytes>", len(source.Data))
+				c.Source = &source
+			}
+			content[j] = c
+		}
+		messages[i] = Message{Role: msg.Role, Content: content}
+	}
+	req.Messages = messages
+
+	payloadBytes, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("<invalid payload: %v>", err)
+	}
+
+	return string(payloadBytes)
+}
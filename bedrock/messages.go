@@ -0,0 +1,33 @@
+package bedrock
+
+// TextContent returns a text content block.
+func TextContent(text string) Content {
+	return Content{Type: contentTypeText, Text: text}
+}
+
+// ImageContent returns an image content block with the base64 encoded image data.
+func ImageContent(mediaType, data string) Content {
+	return Content{
+		Type: contentTypeImage,
+		Source: &Source{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      data,
+		},
+	}
+}
+
+// UserText returns a user message with text.
+func UserText(text string) Message {
+	return Message{Role: roleUser, Content: []Content{TextContent(text)}}
+}
+
+// AssistantText returns an assistant message with text, e.g. a previous response or a prefill.
+func AssistantText(text string) Message {
+	return Message{Role: roleAssistant, Content: []Content{TextContent(text)}}
+}
+
+// UserImage returns a user message with the base64 encoded image data.
+func UserImage(mediaType, data string) Message {
+	return Message{Role: roleUser, Content: []Content{ImageContent(mediaType, data)}}
+}
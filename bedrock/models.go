@@ -0,0 +1,65 @@
+package bedrock
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateModelID checks that modelID is the id of an Anthropic Claude model, which are the
+// only ones that understand Claude3Request. Inference profile ids, like
+// us.anthropic.claude-3-5-sonnet-20240620-v1:0, are checked for the model they route to, and
+// ARNs are accepted as they are.
+func ValidateModelID(modelID string) error {
+
+	if strings.HasPrefix(modelID, "arn:") {
+		return nil
+	}
+
+	model := modelID
+	if IsInferenceProfile(modelID) {
+		_, model, _ = strings.Cut(modelID, ".")
+	}
+
+	if !strings.HasPrefix(model, "anthropic.claude-") {
+		return fmt.Errorf("invalid model id %q, expected an Anthropic Claude model like anthropic.claude-3-haiku-20240307-v1:0", modelID)
+	}
+
+	return nil
+}
+
+// IsInferenceProfile reports whether modelID refers to a (cross-region) inference profile rather
+// than directly to a model. It is only informational, both are passed to Bedrock unchanged.
+func IsInferenceProfile(modelID string) bool {
+
+	if strings.HasPrefix(modelID, "arn:") {
+		return strings.Contains(modelID, "inference-profile/")
+	}
+
+	prefix, model, found := strings.Cut(modelID, ".")
+	return found && prefix != "anthropic" && strings.HasPrefix(model, "anthropic.")
+}
+
+// contextWindows lists the context window, in tokens, of the Claude models on Bedrock. The
+// input and the generated output both have to fit in it.
+var contextWindows = map[string]int{
+	"anthropic.claude-3-sonnet-20240229-v1:0":   200000,
+	"anthropic.claude-3-haiku-20240307-v1:0":    200000,
+	"anthropic.claude-3-opus-20240229-v1:0":     200000,
+	"anthropic.claude-3-5-sonnet-20240620-v1:0": 200000,
+	"anthropic.claude-3-5-sonnet-20241022-v2:0": 200000,
+	"anthropic.claude-3-5-haiku-20241022-v1:0":  200000,
+	"anthropic.claude-v2:1":                     200000,
+	"anthropic.claude-v2":                       100000,
+	"anthropic.claude-instant-v1":               100000,
+}
+
+// ContextWindow returns the context window of modelID in tokens, or 0 if it's unknown (e.g. for ARNs).
+// Inference profile ids are looked up by the model they route to.
+func ContextWindow(modelID string) int {
+
+	if IsInferenceProfile(modelID) && !strings.HasPrefix(modelID, "arn:") {
+		_, modelID, _ = strings.Cut(modelID, ".")
+	}
+
+	return contextWindows[modelID]
+}
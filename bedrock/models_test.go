@@ -0,0 +1,23 @@
+package bedrock
+
+import "testing"
+
+func TestContextWindow(t *testing.T) {
+
+	tests := []struct {
+		modelID string
+		want    int
+	}{
+		{"anthropic.claude-3-haiku-20240307-v1:0", 200000},
+		{"us.anthropic.claude-3-5-sonnet-20240620-v1:0", 200000},
+		{"anthropic.claude-instant-v1", 100000},
+		{"anthropic.claude-9-unknown", 0},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-haiku-20240307-v1:0", 0},
+	}
+
+	for _, test := range tests {
+		if got := ContextWindow(test.modelID); got != test.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", test.modelID, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package bedrock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces requests so that at most a given number are sent per minute. It's a token
+// bucket that holds a single token, so requests are spread out evenly rather than sent in bursts.
+// It's safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// next is when the next request may be sent
+	next time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that allows requestsPerMinute requests per minute.
+func NewRateLimiter(requestsPerMinute int) *RateLimiter {
+	return &RateLimiter{interval: time.Minute / time.Duration(requestsPerMinute)}
+}
+
+// Wait blocks until a request may be sent, or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
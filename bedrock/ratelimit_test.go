@@ -0,0 +1,44 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	// one request every 100ms
+	limiter := NewRateLimiter(600)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := limiter.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first request goes right away
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 requests took %s, want at least 200ms", elapsed)
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+
+	limiter := NewRateLimiter(1)
+	err := limiter.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = limiter.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
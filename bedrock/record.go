@@ -0,0 +1,229 @@
+package bedrock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// recordTypeEndOfResponse is the type of the line written after the chunks of each recorded
+// response. Unlike message_stop, it's written for responses that were cut short as well.
+const recordTypeEndOfResponse = "end_of_response"
+
+// endOfResponse is the line that ends each recorded response
+var endOfResponse = []byte(`{"type":"` + recordTypeEndOfResponse + `"}` + "\n")
+
+// recordingStream passes events through from the underlying stream, writing the bytes of each chunk
+// to w once it has been passed on. When the stream ends or is closed, an end of response line is written.
+type recordingStream struct {
+	bedrockruntime.ResponseStreamReader
+	events    chan types.ResponseStream
+	done      chan struct{}
+	finished  chan struct{}
+	closeOnce sync.Once
+}
+
+func newRecordingStream(stream bedrockruntime.ResponseStreamReader, w io.Writer) *recordingStream {
+
+	r := &recordingStream{
+		ResponseStreamReader: stream,
+		events:               make(chan types.ResponseStream),
+		done:                 make(chan struct{}),
+		finished:             make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.finished)
+		defer close(r.events)
+		defer func() {
+			_, err := w.Write(endOfResponse)
+			if err != nil {
+				slog.Warn("failed to record the end of the response", "error", err)
+			}
+		}()
+
+		for event := range stream.Events() {
+			select {
+			case r.events <- event:
+			case <-r.done:
+				return
+			}
+
+			if chunk, ok := event.(*types.ResponseStreamMemberChunk); ok {
+				err := writeChunk(w, chunk.Value.Bytes)
+				if err != nil {
+					slog.Warn("failed to record stream chunk", "error", err)
+				}
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *recordingStream) Events() <-chan types.ResponseStream {
+	return r.events
+}
+
+// Close closes the underlying stream, and waits for the recording of the response to end so that
+// it doesn't mix with the next one.
+func (r *recordingStream) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	err := r.ResponseStreamReader.Close()
+	<-r.finished
+	return err
+}
+
+func writeChunk(w io.Writer, chunk []byte) error {
+
+	var line bytes.Buffer
+	err := json.Compact(&line, chunk)
+	if err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+
+	_, err = w.Write(line.Bytes())
+	return err
+}
+
+// StreamReplayer plays back chunks recorded with Client.Recorder, without calling Bedrock.
+type StreamReplayer struct {
+	// mu is held while a stream reads from the recording, so that the next one starts where it ended
+	mu      sync.Mutex
+	scanner *bufio.Scanner
+	// the line read ahead by peek, if any
+	peeked []byte
+}
+
+func NewStreamReplayer(r io.Reader) *StreamReplayer {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	return &StreamReplayer{scanner: scanner}
+}
+
+// Next returns a stream with the recorded chunks of the next response, i.e. everything up to the
+// next end of response line. Recordings without them end each response at message_stop. The stream
+// is empty once the recording is exhausted.
+//
+// Each stream has to be read to the end or closed before the next one is read. A stream closed
+// early skips the rest of its response.
+func (r *StreamReplayer) Next() bedrockruntime.ResponseStreamReader {
+
+	stream := &replayStream{
+		events:   make(chan types.ResponseStream),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(stream.finished)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		defer close(stream.events)
+
+		// once the stream is closed, the rest of the response is read without being passed on
+		skip := false
+
+		for {
+			line, ok := r.next()
+			if !ok {
+				break
+			}
+
+			recordType := chunkType(line)
+			if recordType == recordTypeEndOfResponse {
+				return
+			}
+
+			if !skip {
+				select {
+				case stream.events <- &types.ResponseStreamMemberChunk{Value: types.PayloadPart{Bytes: line}}:
+				case <-stream.done:
+					skip = true
+				}
+			}
+
+			if recordType == partialResponseTypeMessageStop {
+				if next, ok := r.peek(); ok && chunkType(next) == recordTypeEndOfResponse {
+					r.peeked = nil
+				}
+				return
+			}
+		}
+
+		stream.err = r.scanner.Err()
+	}()
+
+	return stream
+}
+
+// next returns the next non-empty line of the recording
+func (r *StreamReplayer) next() ([]byte, bool) {
+
+	if r.peeked != nil {
+		line := r.peeked
+		r.peeked = nil
+		return line, true
+	}
+
+	for r.scanner.Scan() {
+		line := bytes.Clone(r.scanner.Bytes())
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, true
+		}
+	}
+
+	return nil, false
+}
+
+// peek returns the next non-empty line of the recording, without consuming it
+func (r *StreamReplayer) peek() ([]byte, bool) {
+
+	line, ok := r.next()
+	r.peeked = line
+
+	return line, ok
+}
+
+// chunkType returns the type of a recorded chunk, or "" if it can't be parsed
+func chunkType(line []byte) string {
+
+	var pr PartialResponse
+	if json.Unmarshal(line, &pr) != nil {
+		return ""
+	}
+
+	return pr.Type
+}
+
+type replayStream struct {
+	events    chan types.ResponseStream
+	done      chan struct{}
+	finished  chan struct{}
+	closeOnce sync.Once
+	// only valid once events is closed
+	err error
+}
+
+func (s *replayStream) Events() <-chan types.ResponseStream {
+	return s.events
+}
+
+// Close stops passing on events, and waits for the rest of the response to be skipped.
+func (s *replayStream) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	<-s.finished
+	return nil
+}
+
+func (s *replayStream) Err() error {
+	return s.err
+}
@@ -0,0 +1,122 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func responseChunks(t *testing.T, id string, words ...string) []types.ResponseStream {
+	t.Helper()
+
+	chunks := []types.ResponseStream{
+		chunk(t, PartialResponse{Type: partialResponseTypeMessageStart, Message: PartialResponseMessage{ID: id}}),
+	}
+	for _, word := range words {
+		chunks = append(chunks, chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: word}}))
+	}
+	chunks = append(chunks,
+		chunk(t, PartialResponse{Type: partialResponseTypeMessageDelta, Delta: Delta{StopReason: "end_turn"}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeMessageStop}),
+	)
+
+	return chunks
+}
+
+func replay(t *testing.T, stream bedrockruntime.ResponseStreamReader) Claude3Response {
+	t.Helper()
+
+	resp, err := ProcessStreamingOutput(stream, func(context.Context, []byte) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestRecordingStream(t *testing.T) {
+
+	var recording bytes.Buffer
+
+	// a response read to the end
+	complete := newRecordingStream(newFakeStream(responseChunks(t, "msg_01", "Hello", " world")...), &recording)
+	resp := replay(t, complete)
+	complete.Close()
+	if resp.Text() != "Hello world" {
+		t.Fatalf("text = %q, want %q", resp.Text(), "Hello world")
+	}
+
+	// a response closed after its first event, so that only that event is recorded
+	aborted := newRecordingStream(newFakeStream(responseChunks(t, "msg_02", "Goodbye")...), &recording)
+	<-aborted.Events()
+	aborted.Close()
+
+	lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+	if want := len(responseChunks(t, "msg_01", "Hello", " world")) + 3; len(lines) != want {
+		t.Fatalf("recorded %d lines, want %d:\n%s", len(lines), want, recording.String())
+	}
+	for _, i := range []int{5, 7} {
+		if chunkType([]byte(lines[i])) != recordTypeEndOfResponse {
+			t.Errorf("line %d = %s, want the end of the response", i, lines[i])
+		}
+	}
+
+	replayer := NewStreamReplayer(&recording)
+
+	if resp := replay(t, replayer.Next()); resp.ID != "msg_01" || resp.Text() != "Hello world" {
+		t.Errorf("first response = %q %q, want %q %q", resp.ID, resp.Text(), "msg_01", "Hello world")
+	}
+	if resp := replay(t, replayer.Next()); resp.ID != "msg_02" || resp.Text() != "" {
+		t.Errorf("second response = %q %q, want %q with no text", resp.ID, resp.Text(), "msg_02")
+	}
+	if resp := replay(t, replayer.Next()); resp.ID != "" {
+		t.Errorf("got response %q after the end of the recording", resp.ID)
+	}
+}
+
+func TestStreamReplayerClose(t *testing.T) {
+
+	var recording bytes.Buffer
+	for _, id := range []string{"msg_01", "msg_02"} {
+		stream := newRecordingStream(newFakeStream(responseChunks(t, id, "Hello", " world")...), &recording)
+		replay(t, stream)
+		stream.Close()
+	}
+
+	replayer := NewStreamReplayer(&recording)
+
+	// closing a stream early skips the rest of its response
+	first := replayer.Next()
+	<-first.Events()
+	first.Close()
+
+	resp := replay(t, replayer.Next())
+	if resp.ID != "msg_02" || resp.Text() != "Hello world" {
+		t.Errorf("response after closing = %q %q, want %q %q", resp.ID, resp.Text(), "msg_02", "Hello world")
+	}
+}
+
+// TestStreamReplayerWithoutMarkers plays back a recording made before the end of each response
+// was marked, which ends responses at message_stop
+func TestStreamReplayerWithoutMarkers(t *testing.T) {
+
+	fixture, err := os.ReadFile("testdata/stream_events.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer := NewStreamReplayer(bytes.NewReader(append(append([]byte{}, fixture...), fixture...)))
+
+	first := replay(t, replayer.Next())
+	second := replay(t, replayer.Next())
+	if first.ID == "" || second.ID != first.ID || second.Text() != first.Text() {
+		t.Errorf("responses = %q %q and %q %q, want the recorded response twice", first.ID, first.Text(), second.ID, second.Text())
+	}
+	if resp := replay(t, replayer.Next()); resp.ID != "" {
+		t.Errorf("got response %q after the end of the recording", resp.ID)
+	}
+}
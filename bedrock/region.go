@@ -0,0 +1,44 @@
+package bedrock
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultRegion is used when neither a region is given explicitly nor AWS_REGION is set. It can
+// be changed at build time with -ldflags "-X github.com/abhirockzz/claude3-bedrock-go/bedrock.DefaultRegion=<region>".
+var DefaultRegion = "us-west-2"
+
+// ResolveRegion returns region if it's set, otherwise the AWS_REGION environment variable or DefaultRegion.
+func ResolveRegion(region string) string {
+
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	return region
+}
+
+// modelRegions lists the regions where the models used by the programs are known to be available.
+// It's not exhaustive, models not listed here are not checked.
+var modelRegions = map[string][]string{
+	"anthropic.claude-3-sonnet-20240229-v1:0": {"us-east-1", "us-west-2", "ap-northeast-1", "ap-south-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1"},
+	"anthropic.claude-3-haiku-20240307-v1:0":  {"us-east-1", "us-west-2", "ap-northeast-1", "ap-south-1", "ap-southeast-2", "ca-central-1", "eu-central-1", "eu-west-1", "eu-west-2", "eu-west-3", "sa-east-1"},
+}
+
+// CheckModelRegion returns an error if modelID is known not to be available in region. The list of
+// regions may well be out of date, so callers only warn about it.
+func CheckModelRegion(modelID, region string) error {
+
+	regions, ok := modelRegions[modelID]
+	if !ok || slices.Contains(regions, region) {
+		return nil
+	}
+
+	return fmt.Errorf("model %s is not available in region %s, use one of: %s", modelID, region, strings.Join(regions, ", "))
+}
@@ -0,0 +1,102 @@
+package bedrock
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultFetchTimeout is the timeout for fetching images and documents, unless a client with
+// a different one is passed to ReadSource.
+const DefaultFetchTimeout = 30 * time.Second
+
+const maxRedirects = 5
+
+// NewHTTPClient returns a client for fetching images and documents, which gives up after timeout
+// and doesn't follow more than maxRedirects redirects.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// IsURL reports whether source should be fetched over the network rather than read from disk.
+func IsURL(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// ReadSource returns the contents of a local file or url. urls are fetched with httpClient, or one
+// created by NewHTTPClient with DefaultFetchTimeout if it's nil, and the response Content-Type must
+// start with contentType. Local paths are resolved with LocalPath.
+func ReadSource(httpClient *http.Client, source, contentType string) ([]byte, error) {
+
+	if IsURL(source) {
+		if httpClient == nil {
+			httpClient = NewHTTPClient(DefaultFetchTimeout)
+		}
+
+		resp, err := httpClient.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s", source, resp.Status)
+		}
+
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), contentType) {
+			return nil, fmt.Errorf("unexpected content type %q for %s, expected %s", resp.Header.Get("Content-Type"), source, contentType)
+		}
+
+		return io.ReadAll(resp.Body)
+	}
+
+	//assume it's local
+	path, err := LocalPath(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// LocalPath resolves source, as copied from a shell or browser, to an absolute path: file://
+// urls are converted to the path they refer to, and a leading ~ stands for the home directory.
+func LocalPath(source string) (string, error) {
+
+	path := source
+
+	if strings.HasPrefix(source, "file://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return "", fmt.Errorf("invalid file url %s: %w", source, err)
+		}
+		path = u.Path
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	return filepath.Abs(path)
+}
@@ -0,0 +1,111 @@
+package bedrock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsURL(t *testing.T) {
+
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"http://example.com/cat.jpg", true},
+		{"https://example.com/cat.jpg", true},
+		{"HTTPS://example.com/cat.jpg", true},
+		{"myhttpphoto.jpg", false},
+		{"http.jpg", false},
+		{"./https/photo.png", false},
+		{"/tmp/http:/photo.png", false},
+		{"httpfoo://example.com/cat.jpg", false},
+		{"ftp://example.com/cat.jpg", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := IsURL(test.source); got != test.want {
+			t.Errorf("IsURL(%q) = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestReadSourceLocalFileNamedHTTP(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "myhttpphoto.jpg")
+	err := os.WriteFile(path, []byte("not really a photo"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ReadSource(nil, path, "image/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "not really a photo" {
+		t.Errorf("unexpected contents %q", contents)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source, want string
+	}{
+		{"/tmp/cat.png", "/tmp/cat.png"},
+		{"/tmp/../tmp/./cat.png", "/tmp/cat.png"},
+		{"cat.png", filepath.Join(wd, "cat.png")},
+		{"~/cat.png", filepath.Join(home, "cat.png")},
+		{"file:///tmp/cat.png", "/tmp/cat.png"},
+		{"file:///tmp/my%20cat.png", "/tmp/my cat.png"},
+	}
+
+	for _, test := range tests {
+		got, err := LocalPath(test.source)
+		if err != nil {
+			t.Errorf("LocalPath(%q): %v", test.source, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("LocalPath(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestReadSourceURL(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			w.Header().Set("Content-Type", "text/html")
+		} else {
+			w.Header().Set("Content-Type", "image/png")
+		}
+		w.Write([]byte("contents"))
+	}))
+	defer server.Close()
+
+	contents, err := ReadSource(nil, server.URL+"/cat.png", "image/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "contents" {
+		t.Errorf("unexpected contents %q", contents)
+	}
+
+	_, err = ReadSource(nil, server.URL+"/page", "image/")
+	if err == nil {
+		t.Error("expected an error for an unexpected content type")
+	}
+}
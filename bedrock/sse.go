@@ -0,0 +1,167 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// SSEHandler serves streamed responses as server-sent events. It accepts a POSTed Claude3Request
+// and sends each text delta as a data event with a {"text": ...} payload. Once the response is
+// complete, a done event carries the stop reason and token usage. If the stream fails after it
+// has started, an error event is sent instead. A request that fails before that is answered with
+// an HTTP error: 429 if it was throttled, 400 if it's invalid and 502 otherwise.
+type SSEHandler struct {
+	client *Client
+
+	// Base, if set, is the request each POSTed request is decoded on top of, so that it supplies
+	// whatever the POSTed request leaves out, e.g. the system prompt or max tokens. Its messages,
+	// if any, come before the POSTed ones.
+	Base *Claude3Request
+}
+
+func NewSSEHandler(client *Client) *SSEHandler {
+	return &SSEHandler{client: client}
+}
+
+type sseText struct {
+	Text string `json:"text"`
+}
+
+type sseDone struct {
+	StopReason string `json:"stop_reason,omitempty"`
+	Usage      Usage  `json:"usage"`
+}
+
+type sseError struct {
+	Error string `json:"error"`
+}
+
+func (h *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	req := h.base()
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if h.Base != nil {
+		req.Messages = append(slices.Clip(h.Base.Messages), req.Messages...)
+	}
+	req = withDefaults(req)
+
+	err = validateMessages(req.Messages)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the event stream starts with the first event, so that the status code can still report
+	// errors until then
+	started := false
+	start := func() {
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Connection", "keep-alive")
+			started = true
+		}
+	}
+
+	resp, err := h.client.InvokeStream(r.Context(), req, func(_ context.Context, part []byte) error {
+		start()
+		err := writeEvent(w, "", sseText{Text: string(part)})
+		if err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to stream the response", "error", err)
+		if !started {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		writeEvent(w, "error", sseError{Error: err.Error()})
+	} else {
+		start()
+		writeEvent(w, "done", sseDone{StopReason: resp.StopReason, Usage: resp.Usage})
+	}
+	flusher.Flush()
+}
+
+// base returns a copy of Base to decode a request on top of, without its messages, which the
+// decoded ones would replace rather than add to
+func (h *SSEHandler) base() Claude3Request {
+
+	if h.Base == nil {
+		return Claude3Request{}
+	}
+
+	req := *h.Base
+	req.Messages = nil
+	// decoding writes through pointers and into the backing arrays of slices, which are shared
+	// with Base
+	req.StopSequences = slices.Clone(req.StopSequences)
+	req.Tools = slices.Clone(req.Tools)
+	if req.Metadata != nil {
+		metadata := *req.Metadata
+		req.Metadata = &metadata
+	}
+	if req.ToolChoice != nil {
+		toolChoice := *req.ToolChoice
+		req.ToolChoice = &toolChoice
+	}
+
+	return req
+}
+
+// errorStatus returns the HTTP status code for a request that failed with err
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeEvent writes data as a JSON encoded server-sent event. An empty event name
+// results in the default message event.
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if event != "" {
+		_, err = fmt.Fprintf(w, "event: %s\n", event)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "data: %s\n\n", dataBytes)
+	return err
+}
@@ -0,0 +1,140 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestSSEHandler(t *testing.T) {
+
+	handler := NewSSEHandler(NewClient(fakeInvoker{}, "model"))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"messages":[{"role":"user","content":[{"type":"text","text":"hello there"}]}]}`)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	want := "data: {\"text\":\"hello \"}\n\n" +
+		"data: {\"text\":\"there\"}\n\n" +
+		"event: done\ndata: {\"stop_reason\":\"end_turn\",\"usage\":{\"input_tokens\":10,\"output_tokens\":2}}\n\n"
+	if recorder.Body.String() != want {
+		t.Errorf("body = %q, want %q", recorder.Body.String(), want)
+	}
+}
+
+func TestSSEHandlerRejectsInvalidRequests(t *testing.T) {
+
+	handler := NewSSEHandler(NewClient(fakeInvoker{}, "model"))
+
+	tests := []struct {
+		method, body string
+		wantStatus   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "not json", http.StatusBadRequest},
+		{http.MethodPost, `{"messages":[]}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+
+		if recorder.Code != test.wantStatus {
+			t.Errorf("%s %q: status = %d, want %d", test.method, test.body, recorder.Code, test.wantStatus)
+		}
+	}
+}
+
+func TestSSEHandlerErrorStatus(t *testing.T) {
+
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{&types.ThrottlingException{Message: aws.String("slow down")}, http.StatusTooManyRequests},
+		{&types.ValidationException{Message: aws.String("too long")}, http.StatusBadRequest},
+		{&types.AccessDeniedException{Message: aws.String("no access")}, http.StatusBadGateway},
+		{errors.New("connection refused"), http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		handler := NewSSEHandler(NewClient(fakeInvoker{err: test.err}, "model"))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"messages":[{"role":"user","content":[{"type":"text","text":"hello"}]}]}`)))
+
+		if recorder.Code != test.wantStatus {
+			t.Errorf("%v: status = %d, want %d", test.err, recorder.Code, test.wantStatus)
+		}
+		if got := recorder.Header().Get("Content-Type"); got == "text/event-stream" {
+			t.Errorf("%v: Content-Type = %q, want a plain error", test.err, got)
+		}
+	}
+}
+
+// streamCaptureInvoker keeps the last streamed request it was sent
+type streamCaptureInvoker struct {
+	fakeInvoker
+	req *Claude3Request
+}
+
+func (i streamCaptureInvoker) InvokeModelWithResponseStream(ctx context.Context, params *bedrockruntime.InvokeModelWithResponseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelWithResponseStreamOutput, error) {
+
+	err := json.Unmarshal(params.Body, i.req)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.fakeInvoker.InvokeModelWithResponseStream(ctx, params, optFns...)
+}
+
+func TestSSEHandlerBase(t *testing.T) {
+
+	var sent Claude3Request
+	handler := NewSSEHandler(NewClient(streamCaptureInvoker{req: &sent}, "model"))
+	handler.Base = &Claude3Request{
+		MaxTokens:     100,
+		Temperature:   0.5,
+		SystemPrompt:  "be brief",
+		StopSequences: []string{"END"},
+		Metadata:      &Metadata{UserID: "user-1"},
+		Messages:      []Message{UserText("primer"), AssistantText("ok")},
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"max_tokens":50,"stop_sequences":["STOP"],"metadata":{"user_id":"user-2"},"messages":[{"role":"user","content":[{"type":"text","text":"hello"}]}]}`)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	// the POSTed request takes precedence, and the base fills in the rest
+	if sent.MaxTokens != 50 || sent.Temperature != 0.5 || sent.SystemPrompt != "be brief" {
+		t.Errorf("max tokens, temperature, system = %d, %v, %q", sent.MaxTokens, sent.Temperature, sent.SystemPrompt)
+	}
+	if !slices.Equal(sent.StopSequences, []string{"STOP"}) || sent.Metadata == nil || sent.Metadata.UserID != "user-2" {
+		t.Errorf("stop sequences, metadata = %q, %+v", sent.StopSequences, sent.Metadata)
+	}
+	if len(sent.Messages) != 3 || sent.Messages[0].Content[0].Text != "primer" || sent.Messages[2].Content[0].Text != "hello" {
+		t.Errorf("messages = %+v", sent.Messages)
+	}
+
+	// the base itself is left as it was
+	if handler.Base.StopSequences[0] != "END" || handler.Base.Metadata.UserID != "user-1" || len(handler.Base.Messages) != 2 {
+		t.Errorf("base = %+v", handler.Base)
+	}
+}
@@ -0,0 +1,51 @@
+package bedrock
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// CallStats describes the attempts the SDK made to get a response, retries included.
+type CallStats struct {
+	// Attempts is the number of requests sent to Bedrock
+	Attempts int
+	// LastRetryableError is the error that caused the last retry, if there was one
+	LastRetryableError error
+
+	// StreamDuration is the time between the first and the last text delta of a streamed response
+	StreamDuration time.Duration
+	// TimeToFirstToken is the time between sending the request and the first text delta of a
+	// streamed response. ProcessStreamingOutput on its own measures it from when it starts reading
+	TimeToFirstToken time.Duration
+}
+
+// TokensPerSecond returns the rate at which the output tokens of a streamed response were generated,
+// or 0 if it's unknown.
+func (s CallStats) TokensPerSecond(usage Usage) float64 {
+
+	if s.StreamDuration <= 0 {
+		return 0
+	}
+
+	return float64(usage.OutputTokens) / s.StreamDuration.Seconds()
+}
+
+// addAttempts fills in the attempts from the metadata of the SDK call
+func (s *CallStats) addAttempts(metadata middleware.Metadata) {
+
+	s.Attempts = 1
+
+	results, ok := retry.GetAttemptResults(metadata)
+	if !ok || len(results.Results) == 0 {
+		return
+	}
+
+	s.Attempts = len(results.Results)
+	for _, result := range results.Results {
+		if result.Err != nil && result.Retryable {
+			s.LastRetryableError = result.Err
+		}
+	}
+}
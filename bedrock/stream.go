@@ -0,0 +1,171 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const contentTypeText = "text"
+const contentTypeImage = "image"
+const contentTypeDocument = "document"
+
+const partialResponseTypeContentBlockStart = "content_block_start"
+const partialResponseTypeContentBlockDelta = "content_block_delta"
+const partialResponseTypeContentBlockStop = "content_block_stop"
+const partialResponseTypeMessageStart = "message_start"
+const partialResponseTypeMessageDelta = "message_delta"
+const partialResponseTypeMessageStop = "message_stop"
+const partialResponseTypePing = "ping"
+
+const deltaTypeInputJSON = "input_json_delta"
+
+// StreamingOutputHandler is invoked with each text delta. Returning an error stops the stream.
+type StreamingOutputHandler func(ctx context.Context, part []byte) error
+
+// StreamingCompletionHandler is invoked once with the complete response after the stream has ended.
+type StreamingCompletionHandler func(ctx context.Context, resp Claude3Response)
+
+// ProcessStreamingOutput reads the events from stream and assembles them into a Claude3Response.
+// stream is usually the result of InvokeModelWithResponseStreamOutput.GetStream, but any
+// bedrockruntime.ResponseStreamReader can be used to feed canned events.
+// If handler returns ErrOutputLimit, the response so far is returned with Truncated set.
+func ProcessStreamingOutput(stream bedrockruntime.ResponseStreamReader, handler StreamingOutputHandler) (Claude3Response, error) {
+
+	resp := Claude3Response{
+		Type: "message",
+		Role: "assistant"}
+
+	// block returns the content block at index, adding (text) blocks as needed
+	block := func(index int) *ResponseContent {
+		for len(resp.ResponseContent) <= index {
+			resp.ResponseContent = append(resp.ResponseContent, ResponseContent{Type: contentTypeText})
+		}
+		return &resp.ResponseContent[index]
+	}
+
+	// the text of each block, and the input of each tool use block, is accumulated in a builder,
+	// since repeated concatenation is quadratic for long responses. result copies them into the
+	// blocks before returning
+	texts := map[int]*strings.Builder{}
+	inputs := map[int]*strings.Builder{}
+	result := func() Claude3Response {
+		for index, text := range texts {
+			block(index).Text = text.String()
+		}
+		for index, input := range inputs {
+			block(index).Input = json.RawMessage(input.String())
+		}
+		return resp
+	}
+	builder := func(builders map[int]*strings.Builder, index int) *strings.Builder {
+		b, ok := builders[index]
+		if !ok {
+			b = &strings.Builder{}
+			builders[index] = b
+		}
+		return b
+	}
+
+	start := time.Now()
+	var firstDelta time.Time
+
+	for event := range stream.Events() {
+		switch v := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+
+			var pr PartialResponse
+			err := json.NewDecoder(bytes.NewReader(v.Value.Bytes)).Decode(&pr)
+			if err != nil {
+				return result(), err
+			}
+
+			switch pr.Type {
+			case partialResponseTypeMessageStart:
+				resp.ID = pr.Message.ID
+				resp.Model = pr.Message.Model
+				resp.Usage.InputTokens = pr.Message.Usage.InputTokens
+				resp.Usage.CacheCreationInputTokens = pr.Message.Usage.CacheCreationInputTokens
+				resp.Usage.CacheReadInputTokens = pr.Message.Usage.CacheReadInputTokens
+			case partialResponseTypeContentBlockStart:
+				// text is accumulated from the deltas. for anything else (e.g. tool_use)
+				// the metadata is recorded so that the tool call can be reconstructed
+				*block(pr.Index) = ResponseContent{
+					Type:  pr.ContentBlock.Type,
+					ID:    pr.ContentBlock.ID,
+					Name:  pr.ContentBlock.Name,
+					Input: pr.ContentBlock.Input,
+				}
+			case partialResponseTypeContentBlockDelta:
+				if pr.Delta.Type == deltaTypeInputJSON {
+					// the input of a tool call isn't text for the handler
+					builder(inputs, pr.Index).WriteString(pr.Delta.PartialJSON)
+					continue
+				}
+
+				err = handler(context.Background(), []byte(pr.Delta.Text))
+				if errors.Is(err, ErrOutputLimit) {
+					resp.Truncated = true
+					return result(), nil
+				}
+				if err != nil {
+					return result(), err
+				}
+				builder(texts, pr.Index).WriteString(pr.Delta.Text)
+
+				if firstDelta.IsZero() {
+					firstDelta = time.Now()
+					resp.Stats.TimeToFirstToken = firstDelta.Sub(start)
+				}
+				resp.Stats.StreamDuration = time.Since(firstDelta)
+			case partialResponseTypeMessageDelta:
+				resp.StopReason = pr.Delta.StopReason
+				resp.StopSequence = pr.Delta.StopSequence
+				resp.Usage.OutputTokens = pr.Usage.OutputTokens
+			case partialResponseTypeContentBlockStop, partialResponseTypeMessageStop, partialResponseTypePing:
+				// nothing to do
+			}
+
+		case *types.UnknownUnionMember:
+			slog.Warn("unknown stream event", "tag", v.Tag)
+
+		default:
+			slog.Warn("stream event is nil or of an unknown type")
+		}
+	}
+
+	// exceptions sent by Bedrock in the middle of the stream end it, and are reported by Err
+	err := stream.Err()
+	if err != nil {
+		return result(), streamError(err)
+	}
+
+	return result(), nil
+}
+
+func streamError(err error) error {
+
+	var modelStreamErr *types.ModelStreamErrorException
+	if errors.As(err, &modelStreamErr) {
+		return fmt.Errorf("%w: model stream error (original status code %d): %s: %w", ErrStream, aws.ToInt32(modelStreamErr.OriginalStatusCode), aws.ToString(modelStreamErr.OriginalMessage), err)
+	}
+
+	// e.g. throttling, which is also reported in the middle of the stream
+	err = classifyError(err)
+
+	var internalServerErr *types.InternalServerException
+	if errors.As(err, &internalServerErr) {
+		return fmt.Errorf("%w: internal server error while streaming: %w", ErrStream, err)
+	}
+
+	return fmt.Errorf("%w: error while streaming: %w", ErrStream, err)
+}
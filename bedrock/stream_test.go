@@ -0,0 +1,241 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// fakeStream is a bedrockruntime.ResponseStreamReader that emits canned events.
+type fakeStream struct {
+	events chan types.ResponseStream
+	err    error
+}
+
+func newFakeStream(events ...types.ResponseStream) *fakeStream {
+	s := &fakeStream{events: make(chan types.ResponseStream, len(events))}
+	for _, e := range events {
+		s.events <- e
+	}
+	close(s.events)
+	return s
+}
+
+func (s *fakeStream) Events() <-chan types.ResponseStream { return s.events }
+func (s *fakeStream) Close() error                        { return nil }
+func (s *fakeStream) Err() error                          { return s.err }
+
+func chunk(t *testing.T, pr PartialResponse) types.ResponseStream {
+	t.Helper()
+
+	b, err := json.Marshal(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.ResponseStreamMemberChunk{Value: types.PayloadPart{Bytes: b}}
+}
+
+func TestProcessStreamingOutput(t *testing.T) {
+
+	stream := newFakeStream(
+		chunk(t, PartialResponse{
+			Type: partialResponseTypeMessageStart,
+			Message: PartialResponseMessage{
+				ID:    "msg_01",
+				Model: "claude-3-haiku-20240307",
+				Usage: PartialResponseUsage{InputTokens: 12},
+			},
+		}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: "Hello"}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: ", "}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: "world"}}),
+		chunk(t, PartialResponse{
+			Type:  partialResponseTypeMessageDelta,
+			Delta: Delta{StopReason: "end_turn"},
+			Usage: PartialResponseUsage{OutputTokens: 7},
+		}),
+	)
+
+	var parts []string
+	resp, err := ProcessStreamingOutput(stream, func(ctx context.Context, part []byte) error {
+		parts = append(parts, string(part))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 3 {
+		t.Errorf("handler called %d times, want 3", len(parts))
+	}
+	if resp.ID != "msg_01" {
+		t.Errorf("ID = %q, want %q", resp.ID, "msg_01")
+	}
+	if resp.Model != "claude-3-haiku-20240307" {
+		t.Errorf("Model = %q, want %q", resp.Model, "claude-3-haiku-20240307")
+	}
+	if got := resp.ResponseContent[0].Text; got != "Hello, world" {
+		t.Errorf("text = %q, want %q", got, "Hello, world")
+	}
+	if resp.Usage.InputTokens != 12 {
+		t.Errorf("InputTokens = %d, want 12", resp.Usage.InputTokens)
+	}
+	if resp.Usage.OutputTokens != 7 {
+		t.Errorf("OutputTokens = %d, want 7", resp.Usage.OutputTokens)
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("StopReason = %q, want %q", resp.StopReason, "end_turn")
+	}
+}
+
+func TestProcessStreamingOutputMalformedChunk(t *testing.T) {
+
+	stream := newFakeStream(
+		&types.ResponseStreamMemberChunk{Value: types.PayloadPart{Bytes: []byte(`{"type": "content_block_delta", "delta": `)}},
+	)
+
+	_, err := ProcessStreamingOutput(stream, func(ctx context.Context, part []byte) error {
+		t.Fatal("handler should not be called")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error for a malformed chunk")
+	}
+}
+
+func TestProcessStreamingOutputModelStreamError(t *testing.T) {
+
+	stream := newFakeStream(
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: "partial"}}),
+	)
+	stream.err = &types.ModelStreamErrorException{
+		Message:            aws.String("model failed"),
+		OriginalStatusCode: aws.Int32(424),
+		OriginalMessage:    aws.String("dependency failed"),
+	}
+
+	resp, err := ProcessStreamingOutput(stream, func(ctx context.Context, part []byte) error {
+		return nil
+	})
+
+	var modelStreamErr *types.ModelStreamErrorException
+	if !errors.As(err, &modelStreamErr) {
+		t.Fatalf("expected a ModelStreamErrorException, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "424") || !strings.Contains(err.Error(), "dependency failed") {
+		t.Errorf("error %q should include the original status code and message", err)
+	}
+	if got := resp.ResponseContent[0].Text; got != "partial" {
+		t.Errorf("text = %q, want the partial response %q", got, "partial")
+	}
+}
+
+func TestProcessStreamingOutputLimit(t *testing.T) {
+
+	stream := newFakeStream(
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: "Hello"}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: ", "}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Delta: Delta{Type: "text_delta", Text: "world"}}),
+	)
+
+	resp, err := ProcessStreamingOutput(stream, LimitOutput(8, func(ctx context.Context, part []byte) error {
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resp.Truncated {
+		t.Error("the response should be truncated")
+	}
+	if got := resp.Text(); got != "Hello, " {
+		t.Errorf("text = %q, want %q", got, "Hello, ")
+	}
+}
+
+// TestProcessStreamingOutputFixture feeds payloads recorded from Bedrock, one event per line,
+// so that changes to the event shapes are caught.
+func TestProcessStreamingOutputFixture(t *testing.T) {
+
+	fixture, err := os.ReadFile("testdata/stream_events.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []types.ResponseStream
+	for _, line := range strings.Split(strings.TrimSpace(string(fixture)), "\n") {
+		events = append(events, &types.ResponseStreamMemberChunk{Value: types.PayloadPart{Bytes: []byte(line)}})
+	}
+
+	resp, err := ProcessStreamingOutput(newFakeStream(events...), func(ctx context.Context, part []byte) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.ID != "msg_bdrk_01VxQ3k6kQy8GZ2wJ9pW5Lr7" {
+		t.Errorf("ID = %q", resp.ID)
+	}
+	if len(resp.ResponseContent) != 1 || resp.ResponseContent[0].Type != contentTypeText {
+		t.Fatalf("content = %+v, want a single text block", resp.ResponseContent)
+	}
+	if got, want := resp.Text(), "Hello! How can I help you today?"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("stop reason = %q, want end_turn", resp.StopReason)
+	}
+	if resp.Usage.InputTokens != 14 {
+		t.Errorf("input tokens = %d, want 14", resp.Usage.InputTokens)
+	}
+	// the usage in message_delta is the final output token count
+	if resp.Usage.OutputTokens != 12 {
+		t.Errorf("output tokens = %d, want 12", resp.Usage.OutputTokens)
+	}
+}
+
+func TestProcessStreamingOutputToolUse(t *testing.T) {
+
+	stream := newFakeStream(
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockStart, Index: 0, ContentBlock: ContentBlock{Type: contentTypeText}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Index: 0, Delta: Delta{Type: "text_delta", Text: "Let me check."}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockStart, Index: 1, ContentBlock: ContentBlock{Type: contentTypeToolUse, ID: "toolu_01", Name: "get_weather", Input: json.RawMessage(`{}`)}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Index: 1, Delta: Delta{Type: deltaTypeInputJSON, PartialJSON: `{"city": "Par`}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeContentBlockDelta, Index: 1, Delta: Delta{Type: deltaTypeInputJSON, PartialJSON: `is"}`}}),
+		chunk(t, PartialResponse{Type: partialResponseTypeMessageDelta, Delta: Delta{StopReason: contentTypeToolUse}}),
+	)
+
+	var text strings.Builder
+	resp, err := ProcessStreamingOutput(stream, func(ctx context.Context, part []byte) error {
+		text.Write(part)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text.String() != "Let me check." {
+		t.Errorf("handler got %q, want only the text", text.String())
+	}
+	if len(resp.ResponseContent) != 2 {
+		t.Fatalf("got %d content blocks, want 2", len(resp.ResponseContent))
+	}
+	if resp.Text() != "Let me check." {
+		t.Errorf("text = %q", resp.Text())
+	}
+
+	tool := resp.ResponseContent[1]
+	if tool.Type != contentTypeToolUse || tool.ID != "toolu_01" || tool.Name != "get_weather" || tool.Text != "" {
+		t.Errorf("tool use block = %+v", tool)
+	}
+	if string(tool.Input) != `{"city": "Paris"}` {
+		t.Errorf("input = %s", tool.Input)
+	}
+}
@@ -0,0 +1,44 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const contentTypeToolUse = "tool_use"
+
+const toolChoiceTypeTool = "tool"
+
+// structuredOutputTool is the name of the tool that ExtractStructured makes the model call
+const structuredOutputTool = "record_output"
+
+// ExtractStructured asks the model to answer prompt with JSON that matches schema, and returns
+// it. The schema is registered as the input of a tool that the model is forced to call, so the
+// answer is the input of that call rather than free text. The request has the settings of req,
+// e.g. a higher MaxTokens for large outputs, with its messages and tools replaced. The anthropic
+// version and max tokens default to DefaultAnthropicVersion and 1024 if they're not set.
+func (c *Client) ExtractStructured(ctx context.Context, req Claude3Request, prompt string, schema json.RawMessage) (json.RawMessage, error) {
+
+	req = withDefaults(req)
+	req.Messages = []Message{UserText(prompt)}
+	req.Tools = []Tool{{
+		Name:        structuredOutputTool,
+		Description: "Records the output in the required format.",
+		InputSchema: schema,
+	}}
+	req.ToolChoice = &ToolChoice{Type: toolChoiceTypeTool, Name: structuredOutputTool}
+
+	resp, err := c.Invoke(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, content := range resp.ResponseContent {
+		if content.Type == contentTypeToolUse && content.Name == structuredOutputTool {
+			return content.Input, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the model didn't call the %s tool (stop reason %q)", structuredOutputTool, resp.StopReason)
+}
@@ -0,0 +1,98 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// toolInvoker answers with a call of the forced tool, with a canned input.
+type toolInvoker struct {
+	fakeInvoker
+	input string
+	// maxTokens, if set, receives the max_tokens of the request
+	maxTokens *int
+}
+
+func (i toolInvoker) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+
+	var req Claude3Request
+	err := json.Unmarshal(params.Body, &req)
+	if err != nil {
+		return nil, err
+	}
+	if i.maxTokens != nil {
+		*i.maxTokens = req.MaxTokens
+	}
+
+	resp := Claude3Response{Role: roleAssistant, StopReason: "end_turn"}
+	if req.ToolChoice != nil && req.ToolChoice.Type == toolChoiceTypeTool {
+		resp.StopReason = contentTypeToolUse
+		resp.ResponseContent = []ResponseContent{{Type: contentTypeToolUse, ID: "toolu_01", Name: req.ToolChoice.Name, Input: json.RawMessage(i.input)}}
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bedrockruntime.InvokeModelOutput{Body: body}, nil
+}
+
+func TestExtractStructured(t *testing.T) {
+
+	client := NewClient(toolInvoker{input: `{"name":"Ada","year":1815}`}, "model")
+
+	schema := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"},"year":{"type":"integer"}}}`)
+	out, err := client.ExtractStructured(context.Background(), Claude3Request{}, "Ada Lovelace was born in 1815.", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var person struct {
+		Name string
+		Year int
+	}
+	err = json.Unmarshal(out, &person)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.Name != "Ada" || person.Year != 1815 {
+		t.Errorf("got %+v", person)
+	}
+}
+
+func TestExtractStructuredWithoutToolCall(t *testing.T) {
+
+	// fakeInvoker answers with text
+	client := NewClient(fakeInvoker{}, "model")
+
+	_, err := client.ExtractStructured(context.Background(), Claude3Request{}, "hello", json.RawMessage(`{"type":"object"}`))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestExtractStructuredMaxTokens(t *testing.T) {
+
+	var maxTokens int
+	client := NewClient(toolInvoker{input: `{}`, maxTokens: &maxTokens}, "model")
+
+	_, err := client.ExtractStructured(context.Background(), Claude3Request{}, "hello", json.RawMessage(`{"type":"object"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxTokens != defaultMaxTokens {
+		t.Errorf("max tokens = %d, want %d", maxTokens, defaultMaxTokens)
+	}
+
+	_, err = client.ExtractStructured(context.Background(), Claude3Request{MaxTokens: 4096}, "hello", json.RawMessage(`{"type":"object"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxTokens != 4096 {
+		t.Errorf("max tokens = %d, want 4096", maxTokens)
+	}
+}
@@ -0,0 +1,71 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const summaryPrompt = "Summarize the conversation between a user and an assistant below. Keep the facts, " +
+	"decisions and open questions needed to carry on with it, and leave out the rest. Only output the summary."
+
+// Summarize replaces the messages in Request.Messages[from:to] with a summary of them written by
+// client, e.g. to keep a long conversation within the context window. client may use a cheaper
+// model than the conversation. The summary is sent as a user message followed by an assistant
+// acknowledgement, so the replaced messages have to start with a user message and come in
+// pairs for the roles to keep alternating. Only the max tokens of the conversation carry over to
+// the summary request, its system prompt, sampling settings, tools and so on are meant for the
+// conversation itself.
+func (c *Conversation) Summarize(ctx context.Context, client *Client, from, to int) (Claude3Response, error) {
+
+	messages := c.Request.Messages
+	if from < 0 || to > len(messages) || from >= to || (to-from)%2 != 0 || messages[from].Role != roleUser {
+		return Claude3Response{}, fmt.Errorf("can't summarize messages %d to %d, they have to be user and assistant pairs", from, to)
+	}
+
+	req := Claude3Request{
+		AnthropicVersion: c.Request.AnthropicVersion,
+		MaxTokens:        c.Request.MaxTokens,
+		Messages:         []Message{UserText(summaryPrompt + "\n\n<conversation>\n" + transcript(messages[from:to]) + "</conversation>")},
+	}
+
+	resp, err := client.Invoke(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Text() == "" {
+		return resp, ErrEmptyResponse
+	}
+
+	summary := []Message{
+		UserText("Here is a summary of our conversation so far:\n\n" + resp.Text()),
+		AssistantText("Thanks, I'll continue from there."),
+	}
+	c.Request.Messages = append(append(append([]Message{}, messages[:from]...), summary...), messages[to:]...)
+
+	return resp, nil
+}
+
+// transcript returns the text of messages, with a label for the role of each
+func transcript(messages []Message) string {
+
+	var text strings.Builder
+	for _, msg := range messages {
+		label := "User"
+		if msg.Role == roleAssistant {
+			label = "Assistant"
+		}
+		text.WriteString(label + ":")
+
+		for _, content := range msg.Content {
+			if content.Type == contentTypeText {
+				text.WriteString(" " + content.Text)
+			} else {
+				text.WriteString(" [" + content.Type + "]")
+			}
+		}
+		text.WriteString("\n\n")
+	}
+
+	return text.String()
+}
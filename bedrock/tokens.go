@@ -0,0 +1,46 @@
+package bedrock
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+)
+
+// rough averages, good enough to catch accidentally huge prompts
+const charsPerToken = 4
+const tokensPerImage = 1600
+
+// each PDF page is sent as both its text and an image, which comes to a few thousand tokens
+// for a typical page of a few dozen KB
+const documentBytesPerToken = 20
+
+// EstimateInputTokens approximates the number of input tokens req will use. Text is counted
+// at charsPerToken characters per token, each image at a fixed tokensPerImage, and documents
+// by their size, at documentBytesPerToken bytes per token.
+func EstimateInputTokens(req Claude3Request) int {
+
+	chars := utf8.RuneCountInString(req.SystemPrompt)
+	if len(req.SystemContent) > 0 {
+		// sent instead of SystemPrompt
+		chars = 0
+		for _, content := range req.SystemContent {
+			chars += utf8.RuneCountInString(content.Text)
+		}
+	}
+	images := 0
+	documentBytes := 0
+
+	for _, msg := range req.Messages {
+		for _, content := range msg.Content {
+			switch {
+			case content.Type == contentTypeImage:
+				images++
+			case content.Type == contentTypeDocument && content.Source != nil:
+				documentBytes += base64.StdEncoding.DecodedLen(len(content.Source.Data))
+			default:
+				chars += utf8.RuneCountInString(content.Text)
+			}
+		}
+	}
+
+	return (chars+charsPerToken-1)/charsPerToken + images*tokensPerImage + (documentBytes+documentBytesPerToken-1)/documentBytesPerToken
+}
@@ -0,0 +1,30 @@
+package bedrock
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestEstimateInputTokens(t *testing.T) {
+
+	pdf := base64.StdEncoding.EncodeToString([]byte("%PDF-" + strings.Repeat("x", 20995)))
+
+	req := Claude3Request{
+		SystemPrompt: "be terse",
+		Messages: []Message{{
+			Role: roleUser,
+			Content: []Content{
+				{Type: contentTypeDocument, Source: &Source{Type: "base64", MediaType: "application/pdf", Data: pdf}},
+				ImageContent("image/png", "iVBORw0KGgo="),
+				TextContent("summarize this"),
+			},
+		}},
+	}
+
+	// 22 characters of text, one image and 21000 bytes of document
+	want := 6 + tokensPerImage + 1050
+	if got := EstimateInputTokens(req); got != want {
+		t.Errorf("got %d tokens, want %d", got, want)
+	}
+}
@@ -0,0 +1,232 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Claude3Request struct {
+	AnthropicVersion string    `json:"anthropic_version"`
+	MaxTokens        int       `json:"max_tokens"`
+	Messages         []Message `json:"messages"`
+	Temperature      float64   `json:"temperature,omitempty"`
+	TopP             float64   `json:"top_p,omitempty"`
+	TopK             int       `json:"top_k,omitempty"`
+	StopSequences    []string  `json:"stop_sequences,omitempty"`
+	SystemPrompt     string    `json:"system,omitempty"`
+	Metadata         *Metadata `json:"metadata,omitempty"`
+
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+
+	// CacheSystemPrompt adds a prompt caching breakpoint after the system prompt. The system
+	// prompt is then sent as a text content block, since that's where cache_control goes.
+	CacheSystemPrompt bool `json:"-"`
+
+	// SystemContent, if set, is sent as the system prompt instead of SystemPrompt. Unlike the
+	// plain string, its text blocks can carry their own cache_control, e.g. to cache only the
+	// part of the system prompt that doesn't change.
+	SystemContent []Content `json:"-"`
+}
+
+func (r Claude3Request) MarshalJSON() ([]byte, error) {
+
+	// the conversion drops the methods, so that this doesn't recurse
+	type request Claude3Request
+
+	system := r.SystemContent
+	if len(system) == 0 && r.CacheSystemPrompt && r.SystemPrompt != "" {
+		system = []Content{{
+			Type:         contentTypeText,
+			Text:         r.SystemPrompt,
+			CacheControl: &CacheControl{Type: cacheControlTypeEphemeral},
+		}}
+	}
+
+	if len(system) == 0 {
+		return json.Marshal(request(r))
+	}
+
+	return json.Marshal(struct {
+		request
+		System []Content `json:"system"`
+	}{
+		request: request(r),
+		System:  system,
+	})
+}
+
+// UnmarshalJSON accepts the system prompt as a string, into SystemPrompt, or as an array of
+// content blocks, into SystemContent. Fields that are not in data are left as they are.
+func (r *Claude3Request) UnmarshalJSON(data []byte) error {
+
+	// as with MarshalJSON, the system field of the wrapper takes precedence over SystemPrompt
+	type request Claude3Request
+	wrapper := struct {
+		*request
+		System json.RawMessage `json:"system"`
+	}{
+		request: (*request)(r),
+	}
+
+	err := json.Unmarshal(data, &wrapper)
+	if err != nil {
+		return err
+	}
+
+	system := wrapper.System
+	switch {
+	case len(system) == 0 || string(system) == "null":
+		return nil
+	case system[0] == '[':
+		var content []Content
+		err = json.Unmarshal(system, &content)
+		if err != nil {
+			return fmt.Errorf("invalid system prompt: %w", err)
+		}
+		r.SystemPrompt, r.SystemContent = "", content
+	default:
+		var prompt string
+		err = json.Unmarshal(system, &prompt)
+		if err != nil {
+			return fmt.Errorf("invalid system prompt: %w", err)
+		}
+		r.SystemPrompt, r.SystemContent = prompt, nil
+	}
+
+	return nil
+}
+
+// Metadata describes the request, e.g. to attribute it to an end user.
+type Metadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Tool describes a tool the model may call. InputSchema is the JSON schema of its input.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice controls how the model uses the tools: "auto", "any" or "tool", which forces
+// the use of the tool called Name.
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type Content struct {
+	Type         string        `json:"type,omitempty"`
+	Source       *Source       `json:"source,omitempty"`
+	Text         string        `json:"text,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks the end of a prompt prefix that can be cached, see
+// https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+const cacheControlTypeEphemeral = "ephemeral"
+
+type Source struct {
+	Type      string `json:"type,omitempty"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+}
+type Message struct {
+	Role    string    `json:"role,omitempty"`
+	Content []Content `json:"content,omitempty"`
+}
+
+type Claude3Response struct {
+	ID              string            `json:"id,omitempty"`
+	Model           string            `json:"model,omitempty"`
+	Type            string            `json:"type,omitempty"`
+	Role            string            `json:"role,omitempty"`
+	ResponseContent []ResponseContent `json:"content,omitempty"`
+	StopReason      string            `json:"stop_reason,omitempty"`
+	StopSequence    string            `json:"stop_sequence,omitempty"`
+	Usage           Usage             `json:"usage,omitempty"`
+
+	// Stats is not part of the model response, it's filled in by the Client
+	Stats CallStats `json:"-"`
+	// Truncated is set when the response was cut short because of LimitOutput
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Text returns the text of all the text content blocks, concatenated.
+func (r Claude3Response) Text() string {
+
+	var text strings.Builder
+	for _, content := range r.ResponseContent {
+		if content.Type == contentTypeText {
+			text.WriteString(content.Text)
+		}
+	}
+
+	return text.String()
+}
+
+type ResponseContent struct {
+	Type  string          `json:"type,omitempty"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+type Usage struct {
+	InputTokens              int `json:"input_tokens,omitempty"`
+	OutputTokens             int `json:"output_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+type PartialResponse struct {
+	Type         string                 `json:"type"`
+	Message      PartialResponseMessage `json:"message,omitempty"`
+	Index        int                    `json:"index,omitempty"`
+	ContentBlock ContentBlock           `json:"content_block,omitempty"`
+	Delta        Delta                  `json:"delta,omitempty"`
+	Usage        PartialResponseUsage   `json:"usage,omitempty"`
+}
+
+type PartialResponseMessage struct {
+	ID           string               `json:"id,omitempty"`
+	Type         string               `json:"type,omitempty"`
+	Role         string               `json:"role,omitempty"`
+	Content      []interface{}        `json:"content,omitempty"`
+	Model        string               `json:"model,omitempty"`
+	StopReason   string               `json:"stop_reason,omitempty"`
+	StopSequence interface{}          `json:"stop_sequence,omitempty"`
+	Usage        PartialResponseUsage `json:"usage,omitempty"`
+}
+
+type PartialResponseUsage struct {
+	InputTokens              int `json:"input_tokens,omitempty"`
+	OutputTokens             int `json:"output_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// ContentBlock is sent with a content_block_start event. For tool use blocks it
+// carries the id and name of the tool being called.
+type ContentBlock struct {
+	Type  string          `json:"type,omitempty"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+type Delta struct {
+	Type string `json:"type,omitempty"`
+	Text string `json:"text,omitempty"`
+	// PartialJSON is a piece of the input of a tool use block, sent with input_json_delta
+	PartialJSON  string `json:"partial_json,omitempty"`
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
+}
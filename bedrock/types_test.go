@@ -0,0 +1,126 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestClaude3RequestCacheSystemPrompt(t *testing.T) {
+
+	req := Claude3Request{
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        10,
+		SystemPrompt:     "be terse",
+	}
+
+	tests := []struct {
+		cache bool
+		want  string
+	}{
+		{false, `{"anthropic_version":"bedrock-2023-05-31","max_tokens":10,"messages":null,"system":"be terse"}`},
+		{true, `{"anthropic_version":"bedrock-2023-05-31","max_tokens":10,"messages":null,"system":[{"type":"text","text":"be terse","cache_control":{"type":"ephemeral"}}]}`},
+	}
+
+	for _, test := range tests {
+		req.CacheSystemPrompt = test.cache
+
+		got, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("cache %v: got %s, want %s", test.cache, got, test.want)
+		}
+	}
+}
+
+func TestClaude3RequestSystemContent(t *testing.T) {
+
+	req := Claude3Request{
+		AnthropicVersion: DefaultAnthropicVersion,
+		MaxTokens:        10,
+		SystemPrompt:     "ignored",
+		SystemContent: []Content{
+			{Type: contentTypeText, Text: "long reference material", CacheControl: &CacheControl{Type: cacheControlTypeEphemeral}},
+			TextContent("today's instructions"),
+		},
+	}
+
+	got, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"anthropic_version":"bedrock-2023-05-31","max_tokens":10,"messages":null,"system":[{"type":"text","text":"long reference material","cache_control":{"type":"ephemeral"}},{"type":"text","text":"today's instructions"}]}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestClaude3RequestSafetyGuardrails(t *testing.T) {
+
+	requests := map[string]Claude3Request{
+		"system prompt":         {SystemPrompt: "be terse"},
+		"cached system prompt":  {SystemPrompt: "be terse", CacheSystemPrompt: true},
+		"system content":        {SystemContent: []Content{TextContent("be terse")}},
+		"applied twice":         {SystemContent: []Content{TextContent("be terse")}},
+		"without system prompt": {},
+	}
+
+	for name, req := range requests {
+		WithSafetyGuardrails(&req)
+		if name == "applied twice" {
+			WithSafetyGuardrails(&req)
+		}
+
+		got, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(got), SafetyGuardrails); n != 1 {
+			t.Errorf("%s: the guardrails are in the request %d times: %s", name, n, got)
+		}
+		if name != "without system prompt" && !strings.Contains(string(got), "be terse") {
+			t.Errorf("%s: the system prompt is missing: %s", name, got)
+		}
+	}
+}
+
+func TestClaude3RequestUnmarshalSystem(t *testing.T) {
+
+	tests := []struct {
+		system            string
+		wantSystemPrompt  string
+		wantSystemContent int
+	}{
+		{`"be terse"`, "be terse", 0},
+		{`[{"type":"text","text":"reference","cache_control":{"type":"ephemeral"}},{"type":"text","text":"be terse"}]`, "", 2},
+	}
+
+	for _, test := range tests {
+		var req Claude3Request
+		err := json.Unmarshal([]byte(`{"max_tokens":10,"system":`+test.system+`}`), &req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.system, err)
+		}
+		if req.MaxTokens != 10 || req.SystemPrompt != test.wantSystemPrompt || len(req.SystemContent) != test.wantSystemContent {
+			t.Errorf("%s: got %+v", test.system, req)
+		}
+
+		// the system prompt is sent the way it was given
+		got, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(got), `"system":`+test.system+`}`) {
+			t.Errorf("%s: marshalled as %s", test.system, got)
+		}
+	}
+
+	var req Claude3Request
+	err := json.Unmarshal([]byte(`{"system":42}`), &req)
+	if err == nil {
+		t.Error("expected an error for a system prompt that is neither a string nor an array")
+	}
+}
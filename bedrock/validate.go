@@ -0,0 +1,31 @@
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+)
+
+// validateMessages checks the constraints Claude puts on the conversation: it has to start with a
+// user message, and the roles have to alternate. The last message may be from the assistant, to
+// prefill the response.
+func validateMessages(messages []Message) error {
+
+	if len(messages) == 0 {
+		return errors.New("invalid messages: there are no messages to send")
+	}
+
+	if messages[0].Role != roleUser {
+		return fmt.Errorf("invalid messages: the first message has to be from the %s, not the %s", roleUser, messages[0].Role)
+	}
+
+	for i, msg := range messages {
+		if msg.Role != roleUser && msg.Role != roleAssistant {
+			return fmt.Errorf("invalid messages: message %d has unknown role %q", i+1, msg.Role)
+		}
+		if i > 0 && msg.Role == messages[i-1].Role {
+			return fmt.Errorf("invalid messages: messages %d and %d are both from the %s, roles have to alternate", i, i+1, msg.Role)
+		}
+	}
+
+	return nil
+}
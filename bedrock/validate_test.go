@@ -0,0 +1,30 @@
+package bedrock
+
+import "testing"
+
+func TestValidateMessages(t *testing.T) {
+
+	user := Message{Role: roleUser}
+	assistant := Message{Role: roleAssistant}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		valid    bool
+	}{
+		{"single user message", []Message{user}, true},
+		{"conversation", []Message{user, assistant, user}, true},
+		{"prefilled response", []Message{user, assistant}, true},
+		{"empty", nil, false},
+		{"assistant first", []Message{assistant, user}, false},
+		{"consecutive user messages", []Message{user, assistant, user, user}, false},
+		{"unknown role", []Message{user, {Role: "system"}}, false},
+	}
+
+	for _, test := range tests {
+		err := validateMessages(test.messages)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected result %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package bedrock
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WrapWriter re-flows the text written to it so that lines are at most width characters
+// wide, breaking them at whitespace. Since a word can span several writes (e.g. streamed
+// deltas), it is buffered until the next whitespace; call Flush once the text is complete.
+// Words longer than width are written on a line of their own. ANSI escape sequences, e.g. for
+// colors, don't count towards the width.
+type WrapWriter struct {
+	w      io.Writer
+	width  int
+	col    int
+	spaces int
+	word   strings.Builder
+	// holds an incomplete UTF-8 sequence left over from the previous write
+	pending []byte
+}
+
+func NewWrapWriter(w io.Writer, width int) *WrapWriter {
+	return &WrapWriter{w: w, width: width}
+}
+
+func (ww *WrapWriter) Write(p []byte) (int, error) {
+
+	buf := append(ww.pending, p...)
+	ww.pending = nil
+
+	for len(buf) > 0 {
+		if !utf8.FullRune(buf) {
+			ww.pending = append([]byte(nil), buf...)
+			break
+		}
+
+		r, size := utf8.DecodeRune(buf)
+		buf = buf[size:]
+
+		var err error
+		switch {
+		case r == '\n':
+			err = ww.flushWord()
+			if err == nil {
+				ww.spaces = 0
+				ww.col = 0
+				_, err = io.WriteString(ww.w, "\n")
+			}
+		case unicode.IsSpace(r):
+			err = ww.flushWord()
+			ww.spaces++
+		default:
+			ww.word.WriteRune(r)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out the buffered word, if any.
+func (ww *WrapWriter) Flush() error {
+	return ww.flushWord()
+}
+
+func (ww *WrapWriter) flushWord() error {
+
+	if ww.word.Len() == 0 {
+		return nil
+	}
+
+	word := ww.word.String()
+	ww.word.Reset()
+	wordLen := visibleLen(word)
+
+	// wrap, dropping the whitespace that separated the word from the previous one
+	if ww.col > 0 && ww.col+ww.spaces+wordLen > ww.width {
+		ww.col = 0
+		ww.spaces = 0
+		_, err := io.WriteString(ww.w, "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(ww.w, strings.Repeat(" ", ww.spaces)+word)
+	ww.col += ww.spaces + wordLen
+	ww.spaces = 0
+
+	return err
+}
+
+// visibleLen returns the number of characters in word that take up a column, i.e. without the
+// ANSI escape sequences
+func visibleLen(word string) int {
+
+	n := 0
+	for i := 0; i < len(word); {
+		if strings.HasPrefix(word[i:], "\x1b[") {
+			// the sequence ends with a byte from @ to ~
+			i += 2
+			for i < len(word) && (word[i] < '@' || word[i] > '~') {
+				i++
+			}
+			i++
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(word[i:])
+		i += size
+		n++
+	}
+
+	return n
+}
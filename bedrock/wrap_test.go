@@ -0,0 +1,54 @@
+package bedrock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapWriter(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{"wraps at whitespace", "The quick brown fox jumps", 10, "The quick\nbrown fox\njumps"},
+		{"multi-byte runes", "héllo wörld", 5, "héllo\nwörld"},
+		{"keeps the spaces between words", "a  b\tc", 80, "a  b c"},
+		{"drops the spaces at a wrap", "aaa   bbb", 5, "aaa\nbbb"},
+		{"newlines reset the column", "ab\ncd ef", 5, "ab\ncd ef"},
+		{"long words on a line of their own", "a verylongword b", 5, "a\nverylongword\nb"},
+		{"escape sequences take no columns", "\x1b[32m[Assistant]: \x1b[0mhello world", 20, "\x1b[32m[Assistant]: \x1b[0mhello\nworld"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// one byte at a time splits both the words and the runes across the deltas
+			var bytewise []string
+			for i := range len(test.text) {
+				bytewise = append(bytewise, test.text[i:i+1])
+			}
+
+			for _, deltas := range [][]string{{test.text}, bytewise} {
+				var out strings.Builder
+				ww := NewWrapWriter(&out, test.width)
+				for _, delta := range deltas {
+					_, err := ww.Write([]byte(delta))
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				err := ww.Flush()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if out.String() != test.want {
+					t.Errorf("%d deltas: got %q, want %q", len(deltas), out.String(), test.want)
+				}
+			}
+		})
+	}
+}
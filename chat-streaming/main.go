@@ -2,247 +2,183 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+	"github.com/abhirockzz/claude3-bedrock-go/internal/chat"
 )
 
-const defaultRegion = "us-east-1"
-
-var brc *bedrockruntime.Client
+const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
 
-func init() {
+func main() {
+	cfg := bedrock.DefaultConfig(modelID)
+	cfg.RegisterFlags(flag.CommandLine)
+	opts := chat.DefaultOptions()
+	opts.RegisterFlags(flag.CommandLine)
+	batch := flag.String("batch", "", "file with prompts to send one by one, one per line. the results are written as JSON lines")
+	batchOutput := flag.String("batch-output", "", "file to write the -batch results to instead of stdout")
+	serve := flag.String("serve", "", "address to serve responses on as server-sent events, e.g. :8080, instead of starting an interactive chat")
+	flag.Parse()
 
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = defaultRegion
+	err := opts.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *serve != "" {
+		// requests are served concurrently, and the prefill is only added by the interactive chat
+		if opts.Record != "" || opts.Raw || opts.Prefill != "" {
+			log.Fatal("-serve can't be used together with -record, -raw or -prefill")
+		}
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	err = chat.InitLogger(opts.LogLevel, opts.Verbose)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	brc = bedrockruntime.NewFromConfig(cfg)
-}
-
-var verbose *bool
-
-const userRole = "user"
-const assistantRole = "assistant"
-const contentTypeText = "text"
-const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
-
-func main() {
-	verbose = flag.Bool("verbose", false, "setting to true will log messages being exchanged with LLM")
-	flag.Parse()
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bedrock.IsInferenceProfile(cfg.ModelID) {
+		slog.Info("using an inference profile", "id", cfg.ModelID)
+	}
 
-	reader := bufio.NewReader(os.Stdin)
+	session, err := chat.New(context.Background(), cfg, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer session.Close()
 
-	payload := Claude3Request{
-		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        1024,
+	payload, err := opts.Request(cfg, flag.CommandLine)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	for {
-		fmt.Print("\nEnter your message: ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-
-		msg := Message{
-			Role: userRole,
-			Content: []Content{
-				{
-					Type: contentTypeText,
-					Text: input,
-				},
-			},
+	if *serve != "" {
+		if session.Client() == nil {
+			log.Fatal("-serve can't be used together with -replay or -dry-run")
 		}
 
-		payload.Messages = append(payload.Messages, msg)
+		// each request carries the whole conversation, on top of the settings of the flags
+		handler := bedrock.NewSSEHandler(session.Client())
+		handler.Base = &payload
 
-		response, err := send(payload)
+		slog.Info("serving responses as server-sent events", "address", *serve)
+		log.Fatal(http.ListenAndServe(*serve, handler))
+	}
 
+	if *batch != "" {
+		err = runBatch(session, payload, *batch, *batchOutput, opts.ContinueOnError)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		//fmt.Println("[Assistant]:", response)
-
-		respMsg := Message{
-			Role: assistantRole,
-			Content: []Content{
-				{
-					Type: contentTypeText,
-					Text: response,
-				},
-			},
-		}
-		payload.Messages = append(payload.Messages, respMsg)
-
+		return
 	}
-}
-
-func send(payload Claude3Request) (string, error) {
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
-	}
+	if opts.Prompt != "" {
+		err = session.SendPrompt(payload, bedrock.UserText(opts.Prompt))
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	if *verbose {
-		fmt.Println("[request payload]", string(payloadBytes))
+		return
 	}
 
-	output, err := brc.InvokeModelWithResponseStream(context.Background(), &bedrockruntime.InvokeModelWithResponseStreamInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-	})
-
-	if err != nil {
-		return "", err
+	prompt := func() string {
+		return fmt.Sprintf("Enter your message%s (%s for multiple lines)", session.ActiveModel(), chat.MultilineDelimiter)
 	}
 
-	fmt.Print("[Assistant]: ")
-
-	resp, err := processStreamingOutput(output, func(ctx context.Context, part []byte) error {
-		fmt.Print(string(part))
-		return nil
+	err = session.Run(payload, prompt, func(input string, _ []bedrock.Message) (bedrock.Message, bool, error) {
+		return bedrock.UserText(input), true, nil
 	})
-
 	if err != nil {
-		log.Fatal("streaming output processing error: ", err)
+		log.Fatal(err)
 	}
-
-	return resp.ResponseContent[0].Text, nil
 }
 
-type Claude3Request struct {
-	AnthropicVersion string    `json:"anthropic_version"`
-	MaxTokens        int       `json:"max_tokens"`
-	Messages         []Message `json:"messages"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	TopK             int       `json:"top_k,omitempty"`
-	StopSequences    []string  `json:"stop_sequences,omitempty"`
-	SystemPrompt     string    `json:"system,omitempty"`
+// batchResult is written to the -batch output for each prompt
+type batchResult struct {
+	Prompt     string         `json:"prompt"`
+	Response   string         `json:"response,omitempty"`
+	StopReason string         `json:"stop_reason,omitempty"`
+	Usage      *bedrock.Usage `json:"usage,omitempty"`
+	Error      string         `json:"error,omitempty"`
 }
 
-type Content struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Message struct {
-	Role    string    `json:"role,omitempty"`
-	Content []Content `json:"content,omitempty"`
-}
-
-type Claude3Response struct {
-	ID              string            `json:"id,omitempty"`
-	Model           string            `json:"model,omitempty"`
-	Type            string            `json:"type,omitempty"`
-	Role            string            `json:"role,omitempty"`
-	ResponseContent []ResponseContent `json:"content,omitempty"`
-	StopReason      string            `json:"stop_reason,omitempty"`
-	StopSequence    string            `json:"stop_sequence,omitempty"`
-	Usage           Usage             `json:"usage,omitempty"`
-}
-type ResponseContent struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Usage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
-}
-
-type PartialResponse struct {
-	Type    string                 `json:"type"`
-	Message PartialResponseMessage `json:"message,omitempty"`
-	Index   int                    `json:"index,omitempty"`
-	Delta   Delta                  `json:"delta,omitempty"`
-	Usage   PartialResponseUsage   `json:"usage,omitempty"`
-}
+// runBatch sends each non-empty line of the prompts file as a separate message, without the
+// rest of the lines as history, and writes the results to resultsFile (or stdout) as JSON lines
+func runBatch(session *chat.Session, payload bedrock.Claude3Request, promptsFile, resultsFile string, continueOnError bool) error {
 
-type PartialResponseMessage struct {
-	ID           string               `json:"id,omitempty"`
-	Type         string               `json:"type,omitempty"`
-	Role         string               `json:"role,omitempty"`
-	Content      []interface{}        `json:"content,omitempty"`
-	Model        string               `json:"model,omitempty"`
-	StopReason   string               `json:"stop_reason,omitempty"`
-	StopSequence interface{}          `json:"stop_sequence,omitempty"`
-	Usage        PartialResponseUsage `json:"usage,omitempty"`
-}
-
-type PartialResponseUsage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
-}
+	prompts, err := os.Open(promptsFile)
+	if err != nil {
+		return err
+	}
+	defer prompts.Close()
 
-type Delta struct {
-	Type       string `json:"type,omitempty"`
-	Text       string `json:"text,omitempty"`
-	StopReason string `json:"stop_reason,omitempty"`
-}
+	results := io.Writer(os.Stdout)
+	if resultsFile != "" {
+		file, err := os.Create(resultsFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
 
-const partialResponseTypeContentBlockDelta = "content_block_delta"
-const partialResponseTypeMessageStart = "message_start"
-const partialResponseTypeMessageDelta = "message_delta"
+		results = file
+	}
+	encoder := json.NewEncoder(results)
 
-type StreamingOutputHandler func(ctx context.Context, part []byte) error
+	// messages from the conversation template are sent along with every prompt
+	seedMessages := payload.Messages[:len(payload.Messages):len(payload.Messages)]
 
-func processStreamingOutput(output *bedrockruntime.InvokeModelWithResponseStreamOutput, handler StreamingOutputHandler) (Claude3Response, error) {
+	scanner := bufio.NewScanner(prompts)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
-	var combinedResult string
-	resp := Claude3Response{
-		Type:            "message",
-		Role:            "assistant",
-		Model:           "claude-3-sonnet-28k-20240229",
-		ResponseContent: []ResponseContent{{Type: contentTypeText}}}
+	for scanner.Scan() {
+		prompt := strings.TrimSpace(scanner.Text())
+		if prompt == "" {
+			continue
+		}
 
-	for event := range output.GetStream().Events() {
-		switch v := event.(type) {
-		case *types.ResponseStreamMemberChunk:
+		msg := bedrock.UserText(prompt)
+		payload.Messages = append(seedMessages, msg)
+		// the responses are only written as part of the results
+		session.StartTurn(io.Discard, msg)
 
-			var pr PartialResponse
-			err := json.NewDecoder(bytes.NewReader(v.Value.Bytes)).Decode(&pr)
-			if err != nil {
-				return resp, err
-			}
+		result := batchResult{Prompt: prompt}
 
-			if pr.Type == partialResponseTypeContentBlockDelta {
-				handler(context.Background(), []byte(pr.Delta.Text))
-				combinedResult += pr.Delta.Text
-			} else if pr.Type == partialResponseTypeMessageStart {
-				resp.ID = pr.Message.ID
-				resp.Usage.InputTokens = pr.Message.Usage.InputTokens
-			} else if pr.Type == partialResponseTypeMessageDelta {
-				resp.StopReason = pr.Delta.StopReason
-				resp.Usage.OutputTokens = pr.Message.Usage.OutputTokens
+		resp, err := session.Send(io.Discard, payload)
+		if errors.Is(err, chat.ErrDryRun) {
+			continue
+		}
+		if err != nil {
+			if !continueOnError {
+				return fmt.Errorf("prompt %q failed: %w", prompt, err)
 			}
+			slog.Error("failed to get a response", "prompt", prompt, "error", err)
+			result.Error = err.Error()
+		} else {
+			result.Response = resp.Text()
+			result.StopReason = resp.StopReason
+			result.Usage = &resp.Usage
+		}
 
-		case *types.UnknownUnionMember:
-			fmt.Println("unknown tag:", v.Tag)
-
-		default:
-			fmt.Println("union is nil or unknown type")
+		err = encoder.Encode(result)
+		if err != nil {
+			return err
 		}
 	}
 
-	//resp.ResponseContent = []ResponseContent{}
-	resp.ResponseContent[0].Text = combinedResult
-
-	return resp, nil
+	return scanner.Err()
 }
@@ -2,161 +2,237 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+	"github.com/abhirockzz/claude3-bedrock-go/internal/chat"
 )
 
-const defaultRegion = "us-east-1"
-
-var brc *bedrockruntime.Client
-
-func init() {
+// const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
+const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
 
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = defaultRegion
+func main() {
+	cfg := bedrock.DefaultConfig(modelID)
+	cfg.RegisterFlags(flag.CommandLine)
+	verbose := flag.Bool("verbose", false, "setting to true will log messages being exchanged with LLM")
+	logLevel := flag.String("log-level", "info", "level of diagnostic messages written to stderr: debug, info, warn or error")
+	msg := flag.String("prompt", "Transcribe the code in the question. Only output the code.", "question to ask about the image(s)")
+	var imagePaths chat.StringList
+	flag.Var(&imagePaths, "image", "path or url of the image to ask about. can be repeated to send multiple images (default soflow.jpg)")
+	safe := flag.Bool("safe", false, "add the standard safety guardrails to the system prompt")
+	showID := flag.Bool("show-id", false, "print the message id of the response, e.g. for support requests")
+	userID := flag.String("user-id", "", "id of the end user to send in the request metadata, e.g. for abuse tracking")
+	out := flag.String("out", "", "file to write the response to instead of stdout. with -verbose, the token usage is included. with -dir, the directory to write a <image>.txt file per image to")
+	dir := flag.String("dir", "", "directory with images to ask the -prompt question about, one request per image")
+	concurrency := flag.Int("concurrency", 4, "number of images processed at the same time with -dir")
+	imageTimeout := flag.Duration("image-timeout", bedrock.DefaultFetchTimeout, "timeout for fetching images from a url")
+	flag.Parse()
+
+	err := chat.InitLogger(*logLevel, *verbose)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	err = cfg.Validate()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if bedrock.IsInferenceProfile(cfg.ModelID) {
+		slog.Info("using an inference profile", "id", cfg.ModelID)
+	}
 
-	brc = bedrockruntime.NewFromConfig(cfg)
-}
+	if *dir != "" && len(imagePaths) > 0 {
+		log.Fatal("-dir can't be used together with -image")
+	}
+	if *concurrency < 1 {
+		log.Fatal("-concurrency must be at least 1")
+	}
 
-// const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
-const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
+	if len(imagePaths) == 0 {
+		imagePaths = chat.StringList{"soflow.jpg"}
+	}
 
-func main() {
+	client, err := bedrock.NewClientFromConfig(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client.HTTPClient = bedrock.NewHTTPClient(*imageTimeout)
 
-	// msg := "If I buy all the items in the menu, how much would it cost me?"
-	// imagePath := "menu.jpg"
+	// the settings of every request. the messages are added for each one
+	payload := cfg.Request()
 
-	msg := "Transcribe the code in the question. Only output the code."
-	imagePath := "soflow.jpg"
+	if *safe {
+		bedrock.WithSafetyGuardrails(&payload)
+	}
+
+	if *userID != "" {
+		payload.Metadata = &bedrock.Metadata{UserID: *userID}
+	}
 
-	// msg := "Can you suggest a solution to the question?"
-	// imagePath := "soflow.jpg"
+	if *dir != "" {
+		err = askAboutDir(context.Background(), client, payload, *dir, *msg, *concurrency, *out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	imageContents, err := readImageAsBase64(imagePath)
+	msgContent, err := imagesContent(client.HTTPClient, imagePaths, *msg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	payload := Claude3Request{
-		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        1024,
-		Messages: []Message{
-			{
-				Role: "user",
-				Content: []Content{
-					{
-						Type: "image",
-						Source: &Source{
-							Type:      "base64",
-							MediaType: "image/jpeg",
-							Data:      imageContents,
-						},
-					},
-					{
-						Type:   "text",
-						Text:   msg,
-						Source: nil,
-					},
-				},
-			},
+	payload.Messages = []bedrock.Message{
+		{
+			Role:    "user",
+			Content: msgContent,
 		},
 	}
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		log.Fatal(err)
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		fmt.Fprintln(os.Stderr, "[request payload]", bedrock.PayloadForLog(payload))
 	}
-	//fmt.Println("request payload:\n", string(payloadBytes))
-
-	output, err := brc.InvokeModel(context.Background(), &bedrockruntime.InvokeModelInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-	})
 
+	resp, err := client.Invoke(context.Background(), payload)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var resp Claude3Response
+	if *out != "" {
+		err = writeResponse(*out, resp, *verbose)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		fmt.Println("response string:\n", resp.Text())
+	}
+	if *showID {
+		fmt.Fprintf(os.Stderr, "[message id: %s]\n", resp.ID)
+	}
+
+}
 
-	err = json.Unmarshal(output.Body, &resp)
+// askAboutDir asks question about each image in dir, with up to concurrency requests at a time.
+// Each request has the settings of payload. The answers are printed in the order of the file names,
+// or written to <outDir>/<image>.txt if outDir is set. An image that fails is reported, and doesn't
+// stop the others.
+func askAboutDir(ctx context.Context, client *bedrock.Client, payload bedrock.Claude3Request, dir, question string, concurrency int, outDir string) error {
 
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && bedrock.IsImageFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no images found in %s", dir)
+	}
+
+	if outDir != "" {
+		err = os.MkdirAll(outDir, 0755)
+		if err != nil {
+			return err
+		}
 	}
 
-	//fmt.Println("response payload:\n", string(output.Body))
+	type result struct {
+		answer string
+		err    error
+	}
+	results := make([]result, len(names))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				answer, err := client.AskAboutImage(ctx, payload, filepath.Join(dir, names[j]), question)
+				results[j] = result{answer, err}
+				slog.Debug("processed image", "image", names[j], "error", err)
+			}
+		}()
+	}
+	for j := range names {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	for j, name := range names {
+		if results[j].err != nil {
+			slog.Error("failed to process image", "image", name, "error", results[j].err)
+			failed++
+			continue
+		}
+
+		if outDir != "" {
+			err = os.WriteFile(filepath.Join(outDir, name+".txt"), []byte(results[j].answer+"\n"), 0644)
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("== %s ==\n%s\n\n", name, results[j].answer)
+		}
+	}
 
-	fmt.Println("response string:\n", resp.ResponseContent[0].Text)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed", failed, len(names))
+	}
 
+	return nil
 }
 
-func readImageAsBase64(filePath string) (string, error) {
-	imageFile, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", err
+// writeResponse writes the text of resp to the file at path, followed by the token usage if withUsage is set
+func writeResponse(path string, resp bedrock.Claude3Response, withUsage bool) error {
+
+	text := resp.Text()
+	if withUsage {
+		text += fmt.Sprintf("\n\n[%d input tokens, %d output tokens]", resp.Usage.InputTokens, resp.Usage.OutputTokens)
 	}
 
-	encodedString := base64.StdEncoding.EncodeToString(imageFile)
+	err := os.WriteFile(path, []byte(text+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write the response to %s: %w", path, err)
+	}
 
-	return encodedString, nil
+	return nil
 }
 
-type Claude3Request struct {
-	AnthropicVersion string    `json:"anthropic_version"`
-	MaxTokens        int       `json:"max_tokens"`
-	Messages         []Message `json:"messages"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	TopK             int       `json:"top_k,omitempty"`
-	StopSequences    []string  `json:"stop_sequences,omitempty"`
-	SystemPrompt     string    `json:"system,omitempty"`
-}
-type Source struct {
-	Type      string `json:"type,omitempty"`
-	MediaType string `json:"media_type,omitempty"`
-	Data      string `json:"data,omitempty"`
-}
-type Content struct {
-	Type   string  `json:"type,omitempty"`
-	Source *Source `json:"source,omitempty"`
-	Text   string  `json:"text,omitempty"`
-}
-type Message struct {
-	Role    string    `json:"role,omitempty"`
-	Content []Content `json:"content,omitempty"`
-}
+// imagesContent returns a content block per image followed by the question. when there are multiple images,
+// each one is captioned ("Image 1:", "Image 2:" and so on) so that the question can refer to them
+func imagesContent(httpClient *http.Client, imagePaths []string, question string) ([]bedrock.Content, error) {
 
-type Claude3Response struct {
-	ID              string            `json:"id,omitempty"`
-	Model           string            `json:"model,omitempty"`
-	Type            string            `json:"type,omitempty"`
-	Role            string            `json:"role,omitempty"`
-	ResponseContent []ResponseContent `json:"content,omitempty"`
-	StopReason      string            `json:"stop_reason,omitempty"`
-	StopSequence    string            `json:"stop_sequence,omitempty"`
-	Usage           Usage             `json:"usage,omitempty"`
-}
-type ResponseContent struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Usage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
+	msgContent := []bedrock.Content{}
+
+	for i, imagePath := range imagePaths {
+		imageContent, err := bedrock.NewImageContent(httpClient, imagePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(imagePaths) > 1 {
+			msgContent = append(msgContent, bedrock.TextContent(fmt.Sprintf("Image %d:", i+1)))
+		}
+
+		msgContent = append(msgContent, imageContent)
+	}
+
+	msgContent = append(msgContent, bedrock.TextContent(question))
+
+	return msgContent, nil
 }
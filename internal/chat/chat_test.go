@@ -0,0 +1,66 @@
+package chat
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+)
+
+func TestEditLastResponse(t *testing.T) {
+
+	messages := []bedrock.Message{bedrock.UserText("what's 2+2?"), bedrock.AssistantText("5")}
+
+	err := editLastResponse(messages, "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages[1].Content[0].Text; got != "4" {
+		t.Errorf("got %q, want %q", got, "4")
+	}
+
+	err = editLastResponse(messages[:1], "4")
+	if err == nil {
+		t.Error("expected an error when the last message is not a response")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+
+	s := &Session{input: bufio.NewReader(strings.NewReader("  hello  \n\"\"\"\nfunc main() {\n\tfmt.Println()\n}\n\"\"\"\nbye\n"))}
+
+	for _, want := range []string{"hello", "func main() {\n\tfmt.Println()\n}", "bye"} {
+		got, err := s.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	if _, err := s.ReadMessage(); err != io.EOF {
+		t.Errorf("got %v at the end of the input, want io.EOF", err)
+	}
+}
+
+func TestRunEndOfInput(t *testing.T) {
+
+	s := &Session{input: bufio.NewReader(strings.NewReader("1\n"))}
+
+	composed := 0
+	err := s.Run(bedrock.Claude3Request{}, func() string { return "" }, func(string, []bedrock.Message) (bedrock.Message, bool, error) {
+		composed++
+		// e.g. a menu that reads more input
+		_, err := s.ReadLine()
+		return bedrock.Message{}, false, err
+	})
+	if err != nil {
+		t.Errorf("got %v, want nil at the end of the input", err)
+	}
+	if composed != 1 {
+		t.Errorf("composed %d messages, want 1", composed)
+	}
+}
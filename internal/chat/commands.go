@@ -0,0 +1,207 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+)
+
+const continueCommand = "/continue"
+const modelCommand = "/model"
+const saveCommand = "/save"
+const editCommand = "/edit"
+
+// runCommand runs input if it's one of the chat commands, e.g. /save, and reports whether it was
+func (s *Session) runCommand(conv *bedrock.Conversation, input string) bool {
+
+	command, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch {
+	case command == modelCommand:
+		err := s.switchModel(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not switch models:", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "switched to", s.client.ModelID())
+		}
+	case input == continueCommand:
+		err := s.continueResponse(&conv.Request)
+		if err != nil && !errors.Is(err, ErrDryRun) {
+			fmt.Fprintln(os.Stderr, "could not continue the response:", err)
+		}
+	case command == editCommand:
+		err := editLastResponse(conv.Request.Messages, arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not edit the response:", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "updated the response")
+		}
+	case command == saveCommand:
+		err := saveLastResponse(conv.Request.Messages, arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "could not save response:", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "saved response to", arg)
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// continueResponse has the model carry on with the last response, e.g. after it was cut off
+// by max_tokens, and adds what it generates to it
+func (s *Session) continueResponse(payload *bedrock.Claude3Request) error {
+
+	last := len(payload.Messages) - 1
+	if last < 0 || payload.Messages[last].Role != assistantRole {
+		return errors.New("there is no response to continue")
+	}
+
+	var partial strings.Builder
+	for _, content := range payload.Messages[last].Content {
+		partial.WriteString(content.Text)
+	}
+	// a final assistant message is rejected if it ends with whitespace
+	text := strings.TrimRightFunc(partial.String(), unicode.IsSpace)
+
+	request := *payload
+	request.Messages = append(payload.Messages[:last:last], bedrock.AssistantText(text))
+
+	resp, err := s.Send(os.Stdout, request)
+	if err != nil {
+		return err
+	}
+
+	payload.Messages[last] = bedrock.AssistantText(text + resp.Text())
+
+	return nil
+}
+
+// switchModel sends the following messages to modelID. the conversation carries on as it is
+func (s *Session) switchModel(modelID string) error {
+
+	if s.client == nil {
+		return errors.New("no model is invoked in -replay and -dry-run modes")
+	}
+	if modelID == "" {
+		return fmt.Errorf("usage: %s <model id>", modelCommand)
+	}
+
+	err := bedrock.ValidateModelID(modelID)
+	if err != nil {
+		return err
+	}
+
+	if s.region != "" {
+		err = bedrock.CheckModelRegion(modelID, s.region)
+		if err != nil {
+			slog.Warn("the model may not be available", "error", err)
+		}
+	}
+
+	s.client = s.client.WithModel(modelID)
+
+	return nil
+}
+
+// saveLastResponse writes the text of the last assistant message to filename
+func saveLastResponse(messages []bedrock.Message, filename string) error {
+
+	if filename == "" {
+		return fmt.Errorf("usage: %s <filename>", saveCommand)
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != assistantRole {
+			continue
+		}
+
+		var text strings.Builder
+		for _, content := range messages[i].Content {
+			text.WriteString(content.Text)
+		}
+
+		return os.WriteFile(filename, []byte(text.String()), 0644)
+	}
+
+	return errors.New("there is no response to save yet")
+}
+
+// editLastResponse replaces the text of the last message, which has to be a response, with
+// replacement. Without one, the text is edited in $EDITOR (vi if it's not set). The edited
+// response is sent as the context of the following messages.
+func editLastResponse(messages []bedrock.Message, replacement string) error {
+
+	last := len(messages) - 1
+	if last < 0 || messages[last].Role != assistantRole {
+		return errors.New("there is no response to edit yet")
+	}
+
+	text := replacement
+	if text == "" {
+		var current strings.Builder
+		for _, content := range messages[last].Content {
+			current.WriteString(content.Text)
+		}
+
+		var err error
+		text, err = editInEditor(current.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		// an empty assistant message would be rejected
+		return errors.New("the response can't be empty")
+	}
+
+	messages[last] = bedrock.AssistantText(text)
+
+	return nil
+}
+
+// editInEditor opens text in $EDITOR and returns the saved result
+func editInEditor(text string) (string, error) {
+
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	file, err := os.CreateTemp("", "response-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(text)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return string(edited), nil
+}
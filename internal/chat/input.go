@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"io"
+	"strings"
+)
+
+// ReadLine reads a line of user input. At the end of the input (e.g. Ctrl-D, or the end of
+// a piped file), it returns io.EOF.
+func (s *Session) ReadLine() (string, error) {
+
+	line, err := s.input.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// MultilineDelimiter on a line of its own starts and ends a message that spans several lines
+const MultilineDelimiter = `"""`
+
+// ReadMessage reads the text of a message. It's usually a single line, but a line with only
+// MultilineDelimiter starts a block that is read until the closing delimiter, e.g. for pasting
+// code. The lines of the block are kept as they are. At the end of the input, it returns io.EOF.
+func (s *Session) ReadMessage() (string, error) {
+
+	input, err := s.ReadLine()
+	if err != nil || input != MultilineDelimiter {
+		return input, err
+	}
+
+	var lines []string
+	for {
+		line, err := s.input.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if strings.TrimSpace(line) == MultilineDelimiter {
+			break
+		}
+		lines = append(lines, strings.TrimRight(line, "\r\n"))
+		// at the end of the input, the block is sent as it is and the next read returns io.EOF
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return strings.Trim(strings.Join(lines, "\n"), "\n"), nil
+}
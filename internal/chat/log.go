@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"log/slog"
+	"os"
+)
+
+// InitLogger sends diagnostic messages to stderr so that they don't mix with the model output.
+// -verbose is a shortcut for -log-level debug
+func InitLogger(logLevel string, verbose bool) error {
+
+	var level slog.Level
+	err := level.UnmarshalText([]byte(logLevel))
+	if err != nil {
+		return err
+	}
+	if verbose {
+		level = slog.LevelDebug
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	// log.Fatal is only used for unrecoverable errors, which should show up regardless of level
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	return nil
+}
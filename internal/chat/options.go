@@ -0,0 +1,283 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+)
+
+// Options holds the command line settings shared by the chat programs, on top of bedrock.Config.
+type Options struct {
+	Verbose  bool
+	LogLevel string
+
+	// Prompt, if set, is sent Count times instead of starting an interactive chat
+	Prompt string
+	Count  int
+
+	// Record and Replay are files with the raw response stream, see bedrock.StreamReplayer
+	Record string
+	Replay string
+	// DryRun prints the request payloads instead of sending them
+	DryRun bool
+
+	// the contents of the requests
+	ConversationTemplate string
+	ContextFile          string
+	SystemFiles          StringList
+	Safe                 bool
+	StopSequences        StringList
+	CacheSystem          bool
+	UserID               string
+	Prefill              string
+
+	Stateless       bool
+	ContinueOnError bool
+	AutoSummarize   int
+	SummaryModel    string
+	RetryEmpty      int
+	Timeout         time.Duration
+	MaxOutputBytes  int
+
+	// how the responses are printed
+	JSON       bool
+	CodeOnly   bool
+	Wrap       int
+	Timestamps bool
+	Echo       bool
+	Color      bool
+	Stats      bool
+	ShowID     bool
+	Timing     bool
+	Raw        bool
+	Tee        string
+}
+
+// DefaultOptions returns the Options used unless a flag says otherwise.
+func DefaultOptions() Options {
+	return Options{LogLevel: "info", Count: 1}
+}
+
+// RegisterFlags defines a flag for each setting in fs, with the current values as the defaults.
+func (o *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Verbose, "verbose", o.Verbose, "setting to true will log messages being exchanged with LLM")
+	fs.StringVar(&o.LogLevel, "log-level", o.LogLevel, "level of diagnostic messages written to stderr: debug, info, warn or error")
+	fs.BoolVar(&o.JSON, "json", o.JSON, "print the complete response as JSON once it's done instead of streaming the text")
+	fs.StringVar(&o.Prompt, "prompt", o.Prompt, "send this message, print the response and exit instead of starting an interactive chat")
+	fs.StringVar(&o.Prefill, "prefill", o.Prefill, "text the assistant response should start with, e.g. { to get JSON output")
+	fs.StringVar(&o.Record, "record", o.Record, "file to record the raw response stream to, for use with -replay")
+	fs.StringVar(&o.Replay, "replay", o.Replay, "file recorded with -record to play the responses back from instead of calling Bedrock")
+	fs.IntVar(&o.Wrap, "wrap", o.Wrap, "wrap the response text at this many columns. 0 disables wrapping")
+	fs.StringVar(&o.UserID, "user-id", o.UserID, "id of the end user to send in the request metadata, e.g. for abuse tracking")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "print the request payload instead of sending it to the model")
+	fs.StringVar(&o.ConversationTemplate, "conversation-template", o.ConversationTemplate, "JSON file with a request (e.g. system prompt and seed messages) to start the conversation from")
+	fs.BoolVar(&o.Stateless, "stateless", o.Stateless, "send each message on its own instead of along with the conversation history")
+	fs.BoolVar(&o.Timestamps, "timestamps", o.Timestamps, "prefix each message in the transcript with the turn number and the time")
+	fs.BoolVar(&o.ContinueOnError, "continue-on-error", o.ContinueOnError, "report a failed message and carry on, instead of exiting")
+	fs.BoolVar(&o.CacheSystem, "cache-system", o.CacheSystem, "cache the system prompt (e.g. from -conversation-template) across requests")
+	fs.BoolVar(&o.Color, "color", o.Color, "color the [You] and [Assistant] labels. ignored if stdout is not a terminal")
+	fs.BoolVar(&o.Stats, "stats", o.Stats, "print the token usage and the generation speed after each response")
+	fs.Var(&o.StopSequences, "stop", "custom text that stops the response when generated. can be repeated")
+	fs.Var(&o.SystemFiles, "system-file", "file with instructions for the system prompt. can be repeated, the files are joined in order")
+	fs.IntVar(&o.MaxOutputBytes, "max-output-bytes", o.MaxOutputBytes, "stop streaming a response once its text exceeds this many bytes. 0 means no limit")
+	fs.BoolVar(&o.Safe, "safe", o.Safe, "add the standard safety guardrails to the start of the system prompt")
+	fs.BoolVar(&o.ShowID, "show-id", o.ShowID, "print the message id of each response, e.g. for support requests")
+	fs.IntVar(&o.RetryEmpty, "retry-empty", o.RetryEmpty, "number of times to send a request again if the response is empty")
+	fs.DurationVar(&o.Timeout, "timeout", o.Timeout, "maximum time a single response may take, e.g. 30s. 0 means no timeout")
+	fs.BoolVar(&o.CodeOnly, "code-only", o.CodeOnly, "print only the fenced code blocks of each response once it's done, e.g. to pipe generated code to a file")
+	fs.BoolVar(&o.Timing, "timing", o.Timing, "print the time to the first token and the total time of each response")
+	fs.StringVar(&o.ContextFile, "context-file", o.ContextFile, "file with background material (docs, notes) to start the conversation with, as an exchange ahead of the first message")
+	fs.BoolVar(&o.Echo, "echo", o.Echo, "print each user message with a [You]: label before the response, for a complete transcript e.g. with -prompt. with -json, it goes to stderr")
+	fs.BoolVar(&o.Raw, "raw", o.Raw, "print the raw JSON of each stream chunk to stderr as it arrives, to debug the streaming protocol")
+	fs.StringVar(&o.Tee, "tee", o.Tee, "file to append the streamed response text to, along with printing it")
+	fs.IntVar(&o.AutoSummarize, "auto-summarize", o.AutoSummarize, "once the conversation has more than this many turns, replace the oldest ones with a summary. 0 disables it")
+	fs.StringVar(&o.SummaryModel, "summary-model", o.SummaryModel, "model to write the -auto-summarize summaries with, e.g. a cheaper one. defaults to -model")
+	fs.IntVar(&o.Count, "count", o.Count, "number of times to send -prompt, each on its own, e.g. to sample the responses")
+}
+
+// Validate checks the combinations of settings before anything is sent.
+func (o Options) Validate() error {
+
+	if o.Count < 1 {
+		return errors.New("-count must be at least 1")
+	}
+	if o.Count > 1 && o.Prompt == "" {
+		return errors.New("-count can only be used together with -prompt")
+	}
+
+	if o.CodeOnly && o.JSON {
+		return errors.New("-code-only can't be used together with -json")
+	}
+
+	if o.Record != "" && (o.Replay != "" || o.DryRun) {
+		return errors.New("-record can't be used together with -replay or -dry-run")
+	}
+
+	// both need a client, which isn't created in these modes
+	offline := o.Replay != "" || o.DryRun
+	if o.Raw && offline {
+		return errors.New("-raw can't be used together with -replay or -dry-run")
+	}
+	if o.AutoSummarize > 0 && offline {
+		return errors.New("-auto-summarize can't be used together with -replay or -dry-run")
+	}
+
+	if o.SummaryModel != "" {
+		err := bedrock.ValidateModelID(o.SummaryModel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Request returns the request to start the conversation from, with the settings of cfg and the
+// system prompt, seed messages and other contents set by the options. Flags set in fs take
+// precedence over the conversation template.
+func (o Options) Request(cfg bedrock.Config, fs *flag.FlagSet) (bedrock.Claude3Request, error) {
+
+	payload := cfg.Request()
+
+	if o.ConversationTemplate != "" {
+		var err error
+		payload, err = loadConversationTemplate(o.ConversationTemplate)
+		if err != nil {
+			return bedrock.Claude3Request{}, err
+		}
+
+		// flags set on the command line take precedence over the template
+		setFlags := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+		if setFlags["anthropic-version"] || payload.AnthropicVersion == "" {
+			payload.AnthropicVersion = cfg.AnthropicVersion
+		}
+		if setFlags["max-tokens"] || payload.MaxTokens == 0 {
+			payload.MaxTokens = cfg.MaxTokens
+		}
+		if setFlags["temperature"] {
+			payload.Temperature = cfg.Temperature
+		}
+		if setFlags["top-p"] {
+			payload.TopP = cfg.TopP
+		}
+	}
+
+	if o.ContextFile != "" {
+		primer, err := contextMessages(o.ContextFile)
+		if err != nil {
+			return bedrock.Claude3Request{}, err
+		}
+		payload.Messages = append(primer, payload.Messages...)
+	}
+
+	if len(o.SystemFiles) > 0 {
+		var err error
+		payload.SystemPrompt, err = readSystemPrompt(o.SystemFiles)
+		if err != nil {
+			return bedrock.Claude3Request{}, err
+		}
+	}
+
+	if o.Safe {
+		bedrock.WithSafetyGuardrails(&payload)
+	}
+
+	if len(o.StopSequences) > 0 {
+		payload.StopSequences = o.StopSequences
+	}
+
+	if o.UserID != "" {
+		payload.Metadata = &bedrock.Metadata{UserID: o.UserID}
+	}
+
+	if o.CacheSystem {
+		if payload.SystemPrompt == "" {
+			slog.Warn("-cache-system has no effect without a system prompt")
+		}
+		payload.CacheSystemPrompt = true
+	}
+
+	return payload, nil
+}
+
+// systemPromptSeparator goes between the contents of the -system-file files
+const systemPromptSeparator = "\n\n"
+
+// readSystemPrompt joins the contents of files into a system prompt
+func readSystemPrompt(files []string) (string, error) {
+
+	var parts []string
+	for _, file := range files {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, strings.TrimSpace(string(contents)))
+	}
+
+	return strings.Join(parts, systemPromptSeparator), nil
+}
+
+// contextMessages returns a user message with the contents of file as background material, and
+// an assistant message acknowledging it. Being part of the seed messages, they are sent with every
+// request, even in -stateless mode.
+func contextMessages(file string) ([]bedrock.Message, error) {
+
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(string(contents))
+	if text == "" {
+		return nil, fmt.Errorf("context file %s is empty", file)
+	}
+
+	return []bedrock.Message{
+		bedrock.UserText("Here is some background material for our conversation. Use it as a reference " +
+			"when answering my questions.\n\n<context>\n" + text + "\n</context>"),
+		bedrock.AssistantText("Thanks, I've read the background material and will refer to it as needed."),
+	}, nil
+}
+
+// loadConversationTemplate reads a Claude3Request from a JSON file
+func loadConversationTemplate(filename string) (bedrock.Claude3Request, error) {
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return bedrock.Claude3Request{}, err
+	}
+	defer file.Close()
+
+	var template bedrock.Claude3Request
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&template)
+	if err != nil {
+		return bedrock.Claude3Request{}, fmt.Errorf("invalid conversation template %s: %w", filename, err)
+	}
+
+	return template, nil
+}
+
+// StringList collects the values of a repeated flag
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
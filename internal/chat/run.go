@@ -0,0 +1,146 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+)
+
+// SendPrompt sends msg along with the messages of payload, -count times, each on its own, and
+// prints the responses. With -continue-on-error, a failed sample is reported and doesn't stop
+// the others.
+func (s *Session) SendPrompt(payload bedrock.Claude3Request, msg bedrock.Message) error {
+
+	payload.Messages = append(payload.Messages, msg)
+
+	failed := 0
+	for i := 1; i <= s.opts.Count; i++ {
+		// with -json, each sample is a line of its own
+		if s.opts.Count > 1 && !s.opts.JSON {
+			fmt.Printf("--- sample %d of %d ---\n", i, s.opts.Count)
+		}
+		s.StartTurn(s.transcript(), msg)
+
+		_, err := s.Send(os.Stdout, payload)
+		switch {
+		case err == nil, errors.Is(err, ErrDryRun):
+		case s.opts.ContinueOnError:
+			slog.Error("failed to get a response", "sample", i, "error", err)
+			failed++
+		default:
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d samples failed", failed, s.opts.Count)
+	}
+
+	return nil
+}
+
+// Composer turns the input read for a message into the message to send, reading more input as
+// needed. history has the messages that will be sent along with it. It returns false if there
+// is nothing to send, and an error if more input couldn't be read, e.g. io.EOF at its end.
+type Composer func(input string, history []bedrock.Message) (bedrock.Message, bool, error)
+
+// Run starts an interactive chat that continues from the messages in payload, if any, and ends
+// with the input. prompt returns the text shown before each message. Input other than the chat
+// commands (/continue, /edit, /model and /save) is passed to compose. It returns nil at the end
+// of the input, after reporting the session totals with -stats, or the error that stopped the chat.
+func (s *Session) Run(payload bedrock.Claude3Request, prompt func() string, compose Composer) error {
+
+	// messages from the conversation template, which are sent even in -stateless mode
+	seedMessages := len(payload.Messages)
+	conv := bedrock.NewConversation(s.client, payload)
+
+	for {
+		fmt.Fprintf(os.Stderr, "\n%s: ", prompt())
+		input, err := s.ReadMessage()
+		if err != nil {
+			return s.end(err)
+		}
+		if input == "" {
+			// an empty message would be rejected
+			continue
+		}
+
+		if s.runCommand(conv, input) {
+			continue
+		}
+
+		history := conv.Request.Messages
+		if s.opts.Stateless {
+			history = history[:seedMessages]
+		}
+
+		msg, ok, err := compose(input, history)
+		if err != nil {
+			return s.end(err)
+		}
+		if !ok {
+			continue
+		}
+
+		if s.opts.Stateless {
+			// the previous exchange is kept until now so that it can be saved with /save
+			conv.Request.Messages = history
+		} else if s.opts.AutoSummarize > 0 {
+			s.summarizeHistory(conv, seedMessages)
+		}
+		conv.AppendContent(msg.Content...)
+		s.StartTurn(s.transcript(), msg)
+
+		// the user message is dropped if there is no response to it, e.g. in -dry-run mode,
+		// after an error or a timeout before anything was generated
+		_, err = conv.SendWith(context.Background(), s.sendTo(os.Stdout))
+
+		switch {
+		case errors.Is(err, ErrDryRun):
+			continue
+		case errors.Is(err, bedrock.ErrEmptyResponse):
+			fmt.Fprintln(os.Stderr, "empty response, message discarded")
+			continue
+		case err != nil && s.opts.ContinueOnError:
+			slog.Error("failed to get a response", "error", err)
+			continue
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// summarizeHistory replaces the oldest turns after the seed messages with a summary once there
+// are more than -auto-summarize, keeping the latest half. A failed summary is reported, and the
+// conversation carries on as it is.
+func (s *Session) summarizeHistory(conv *bedrock.Conversation, seedMessages int) {
+
+	maxTurns := s.opts.AutoSummarize
+	turns := (len(conv.Request.Messages) - seedMessages) / 2
+	if turns <= maxTurns {
+		return
+	}
+
+	keep := max(maxTurns/2, 1)
+	to := len(conv.Request.Messages) - keep*2
+
+	// the client is taken as it is now, so that the summaries follow /model unless -summary-model is set
+	summarizer := s.client
+	if s.opts.SummaryModel != "" {
+		summarizer = s.client.WithModel(s.opts.SummaryModel)
+	}
+
+	slog.Info("summarizing the oldest turns of the conversation", "turns", turns-keep, "model", summarizer.ModelID())
+
+	resp, err := conv.Summarize(context.Background(), summarizer, seedMessages, to)
+	if err != nil {
+		slog.Error("failed to summarize the conversation", "error", err)
+		return
+	}
+
+	slog.Info("summarized the conversation", "input_tokens", resp.Usage.InputTokens, "output_tokens", resp.Usage.OutputTokens)
+}
@@ -0,0 +1,489 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+)
+
+// Session sends messages to the model and prints the responses, as configured by Options.
+type Session struct {
+	opts Options
+
+	// client is nil in -replay and -dry-run modes
+	client   *bedrock.Client
+	replayer *bedrock.StreamReplayer
+	// modelID is the model the session started with, used when there is no client
+	modelID string
+	// region is checked for the availability of the model when switching models. it's
+	// empty when a custom endpoint is used
+	region string
+
+	input *bufio.Reader
+	// tee, if set, receives the streamed text as well
+	tee io.Writer
+	// files are closed by Close
+	files []*os.File
+
+	// colorLabels is set with -color when stdout is a terminal
+	colorLabels bool
+
+	// turn is the number of the current exchange, shown with -timestamps
+	turn int
+	// usage adds up the tokens of the whole session, reported with -stats
+	usage bedrock.Usage
+}
+
+// ErrDryRun is returned by Send in -dry-run mode, after printing the payload
+var ErrDryRun = errors.New("dry run, the payload was not sent")
+
+const userRole = "user"
+const assistantRole = "assistant"
+const contentTypeText = "text"
+
+// New creates a session that reads messages from stdin and sends them with the settings in cfg
+// and opts, which are expected to be valid. The files it opens are closed by Close.
+func New(ctx context.Context, cfg bedrock.Config, opts Options) (*Session, error) {
+
+	s := &Session{
+		opts:        opts,
+		modelID:     cfg.ModelID,
+		input:       bufio.NewReader(os.Stdin),
+		colorLabels: opts.Color && isTerminal(os.Stdout),
+	}
+	// the prefill is sent as the final assistant message, which is rejected if it ends with whitespace
+	s.opts.Prefill = strings.TrimRightFunc(opts.Prefill, unicode.IsSpace)
+
+	if opts.Replay != "" {
+		replayFile, err := s.open(opts.Replay, os.O_RDONLY)
+		if err != nil {
+			return nil, err
+		}
+
+		s.replayer = bedrock.NewStreamReplayer(replayFile)
+	} else if !opts.DryRun {
+		var err error
+		s.client, err = bedrock.NewClientFromConfig(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		// a custom endpoint may well serve any model, so models are only checked against the region without one
+		if cfg.Endpoint == "" {
+			s.region = bedrock.ResolveRegion(cfg.Region)
+		}
+	}
+
+	if opts.Record != "" {
+		recordFile, err := s.open(opts.Record, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.client.Recorder = recordFile
+	}
+
+	if opts.Tee != "" {
+		teeFile, err := s.open(opts.Tee, os.O_APPEND|os.O_CREATE|os.O_WRONLY)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		s.tee = teeFile
+	}
+
+	if opts.Raw {
+		// the chunks are recorded to stderr, along with the -record file if there is one
+		if s.client.Recorder != nil {
+			s.client.Recorder = io.MultiWriter(s.client.Recorder, os.Stderr)
+		} else {
+			s.client.Recorder = os.Stderr
+		}
+	}
+
+	if opts.AutoSummarize > 0 && opts.Stateless {
+		slog.Warn("-auto-summarize has no effect with -stateless")
+	}
+
+	return s, nil
+}
+
+func (s *Session) open(name string, flag int) (*os.File, error) {
+
+	file, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.files = append(s.files, file)
+
+	return file, nil
+}
+
+// Close closes the files opened for the session.
+func (s *Session) Close() error {
+
+	var errs []error
+	for _, file := range s.files {
+		errs = append(errs, file.Close())
+	}
+	s.files = nil
+
+	return errors.Join(errs...)
+}
+
+// Client returns the client that sends the messages. It's nil in -replay and -dry-run modes.
+func (s *Session) Client() *bedrock.Client {
+	return s.client
+}
+
+// ActiveModel returns the model in use, to be shown in the prompt
+func (s *Session) ActiveModel() string {
+
+	if s.client == nil {
+		return ""
+	}
+
+	return " [" + s.client.ModelID() + "]"
+}
+
+// end ends the chat with err, the error that stopped reading the input. The end of the input
+// isn't an error, and the session totals are reported with -stats.
+func (s *Session) end(err error) error {
+
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if s.opts.Stats {
+		fmt.Fprintf(os.Stderr, "\n[session: %d turns, %d input tokens, %d output tokens]\n", s.turn, s.usage.InputTokens, s.usage.OutputTokens)
+	}
+
+	return nil
+}
+
+// contextWarningRatio is the share of the context window above which checkContextWindow warns
+const contextWarningRatio = 0.9
+
+// checkContextWindow warns when the estimated size of payload, including the tokens it may
+// generate, approaches or exceeds the context window of the model. Bedrock rejects such
+// requests with a ValidationException.
+func (s *Session) checkContextWindow(payload bedrock.Claude3Request) {
+
+	model := s.modelID
+	if s.client != nil {
+		model = s.client.ModelID()
+	}
+
+	window := bedrock.ContextWindow(model)
+	if window == 0 {
+		return
+	}
+
+	estimate := bedrock.EstimateInputTokens(payload) + payload.MaxTokens
+	switch {
+	case estimate > window:
+		slog.Warn("the conversation is likely too long for the context window of the model. start over, use -stateless "+
+			"to only send the latest message, or switch to a model with a larger context window with /model",
+			"estimated_tokens", estimate, "context_window", window)
+	case estimate > int(float64(window)*contextWarningRatio):
+		slog.Warn("the conversation is approaching the context window of the model", "estimated_tokens", estimate, "context_window", window)
+	}
+}
+
+// sendTo returns a function that sends a request with Send, for Conversation.SendWith
+func (s *Session) sendTo(w io.Writer) func(context.Context, bedrock.Claude3Request) (bedrock.Claude3Response, error) {
+	return func(_ context.Context, payload bedrock.Claude3Request) (bedrock.Claude3Response, error) {
+		return s.Send(w, payload)
+	}
+}
+
+// Send streams the response to payload to w, and returns it once it's complete
+func (s *Session) Send(w io.Writer, payload bedrock.Claude3Request) (bedrock.Claude3Response, error) {
+
+	// when continuing a response, it takes the place of the prefill
+	prefill := s.opts.Prefill
+	if len(payload.Messages) > 0 && payload.Messages[len(payload.Messages)-1].Role == assistantRole {
+		prefill = ""
+	}
+
+	if prefill != "" {
+		// use a full slice expression so that the caller's messages are not modified
+		payload.Messages = append(payload.Messages[:len(payload.Messages):len(payload.Messages)], bedrock.AssistantText(prefill))
+	}
+
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		fmt.Fprintln(os.Stderr, "[request payload]", bedrock.PayloadForLog(payload))
+	}
+	slog.Debug("estimated input tokens", "tokens", bedrock.EstimateInputTokens(payload))
+	s.checkContextWindow(payload)
+
+	if s.opts.DryRun {
+		prettyPayload, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			return bedrock.Claude3Response{}, err
+		}
+		fmt.Println(string(prettyPayload))
+
+		return bedrock.Claude3Response{}, ErrDryRun
+	}
+
+	out := w
+	var wrapper *bedrock.WrapWriter
+	if s.opts.Wrap > 0 {
+		wrapper = bedrock.NewWrapWriter(w, s.opts.Wrap)
+		out = wrapper
+	}
+
+	// with -json and -code-only, the response is printed once it's complete
+	streamText := !s.opts.JSON && !s.opts.CodeOnly
+
+	prefixPrinted := false
+	printPrefix := func() {
+		if streamText && !prefixPrinted {
+			fmt.Fprint(out, s.turnPrefix(), s.roleLabel(w, assistantRole), prefill)
+			prefixPrinted = true
+		}
+	}
+	// with -timestamps, the prefix is printed once the response starts arriving
+	if !s.opts.Timestamps {
+		printPrefix()
+	}
+
+	ctx := context.Background()
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	handler := func(_ context.Context, part []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		printPrefix()
+		if streamText {
+			_, err := fmt.Fprint(out, string(part))
+			return err
+		}
+		return nil
+	}
+
+	if s.tee != nil {
+		handler = bedrock.MultiHandler(handler, bedrock.WriterHandler(s.tee))
+	}
+
+	if s.opts.MaxOutputBytes > 0 {
+		handler = bedrock.LimitOutput(s.opts.MaxOutputBytes, handler)
+	}
+
+	start := time.Now()
+	var resp bedrock.Claude3Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		if s.replayer != nil {
+			stream := s.replayer.Next()
+			resp, err = bedrock.ProcessStreamingOutput(stream, handler)
+			stream.Close()
+		} else {
+			resp, err = s.client.InvokeStream(ctx, payload, handler)
+		}
+
+		// an empty or blank response is occasionally returned because of transient issues.
+		// unlike throttling errors, they are not retried by the SDK
+		if err != nil || strings.TrimSpace(resp.Text()) != "" || attempt == s.opts.RetryEmpty {
+			break
+		}
+		slog.Warn("the response is empty, sending the request again", "attempt", attempt+1)
+	}
+
+	printPrefix()
+	if wrapper != nil {
+		wrapper.Flush()
+	}
+	if s.tee != nil {
+		// to separate the responses
+		fmt.Fprintln(s.tee)
+	}
+
+	// on timeout, keep whatever was generated so far
+	timedOut := err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		err = nil
+	}
+
+	switch {
+	case bedrock.IsCredentialsError(err):
+		return bedrock.Claude3Response{}, fmt.Errorf("the AWS credentials were rejected or have expired. %s: %w", bedrock.CredentialsHelp, err)
+	case errors.Is(err, bedrock.ErrThrottled):
+		return bedrock.Claude3Response{}, fmt.Errorf("the request was throttled even after retries. send fewer requests, e.g. with -rpm, or ask for a higher quota: %w", err)
+	case err != nil:
+		return bedrock.Claude3Response{}, err
+	}
+
+	slog.Debug("usage", "input_tokens", resp.Usage.InputTokens, "output_tokens", resp.Usage.OutputTokens,
+		"cache_creation_input_tokens", resp.Usage.CacheCreationInputTokens, "cache_read_input_tokens", resp.Usage.CacheReadInputTokens)
+
+	if resp.Stats.Attempts > 1 {
+		slog.Info("the response needed retries", "attempts", resp.Stats.Attempts, "last_error", resp.Stats.LastRetryableError)
+	}
+
+	if prefill != "" {
+		if len(resp.ResponseContent) > 0 && resp.ResponseContent[0].Type == contentTypeText {
+			resp.ResponseContent[0].Text = prefill + resp.ResponseContent[0].Text
+		} else {
+			resp.ResponseContent = append([]bedrock.ResponseContent{{Type: contentTypeText, Text: prefill}}, resp.ResponseContent...)
+		}
+	}
+
+	if s.opts.JSON {
+		respBytes, err := json.Marshal(resp)
+		if err != nil {
+			return bedrock.Claude3Response{}, err
+		}
+		fmt.Fprintln(w, string(respBytes))
+	} else {
+		if s.opts.CodeOnly {
+			for _, block := range bedrock.ExtractCodeBlocks(resp.Text()) {
+				fmt.Fprintln(w, block.Body)
+			}
+		} else {
+			fmt.Fprintln(w)
+		}
+		for _, content := range resp.ResponseContent {
+			if content.Type != contentTypeText {
+				fmt.Fprintf(os.Stderr, "[%s block: %s %s]\n", content.Type, content.Name, content.Input)
+			}
+		}
+		if note := stopReasonNote(resp); note != "" {
+			fmt.Fprintln(os.Stderr, note)
+		}
+	}
+
+	if resp.Truncated {
+		fmt.Fprintln(os.Stderr, "[response truncated: -max-output-bytes reached]")
+	}
+
+	if timedOut {
+		fmt.Fprintf(os.Stderr, "[timed out after %s]\n", s.opts.Timeout)
+	}
+
+	if s.opts.ShowID && resp.ID != "" {
+		fmt.Fprintf(os.Stderr, "[message id: %s]\n", resp.ID)
+	}
+
+	if s.opts.Timing {
+		fmt.Fprintf(os.Stderr, "[first token after %s, total %s]\n",
+			resp.Stats.TimeToFirstToken.Round(time.Millisecond), time.Since(start).Round(time.Millisecond))
+	}
+
+	s.usage.InputTokens += resp.Usage.InputTokens
+	s.usage.OutputTokens += resp.Usage.OutputTokens
+
+	if s.opts.Stats {
+		fmt.Fprintf(os.Stderr, "[%d input tokens, %d output tokens, %.1f tokens/sec]\n",
+			resp.Usage.InputTokens, resp.Usage.OutputTokens, resp.Stats.TokensPerSecond(resp.Usage))
+	}
+
+	return resp, nil
+}
+
+// StartTurn counts a new exchange and, with -timestamps or -echo, adds the user message to the transcript in w
+func (s *Session) StartTurn(w io.Writer, msg bedrock.Message) {
+
+	s.turn++
+	if !s.opts.Timestamps && !s.opts.Echo {
+		return
+	}
+
+	var text []string
+	for _, content := range msg.Content {
+		if content.Type == contentTypeText {
+			text = append(text, content.Text)
+		} else {
+			text = append(text, "["+content.Type+"]")
+		}
+	}
+
+	fmt.Fprintf(w, "%s%s%s\n", s.turnPrefix(), s.roleLabel(w, userRole), strings.Join(text, " "))
+}
+
+// transcript returns where StartTurn writes the user messages. with -json, stdout only has the
+// responses, one JSON object per line, so that it stays machine-readable
+func (s *Session) transcript() io.Writer {
+
+	if s.opts.JSON {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
+const ansiReset = "\033[0m"
+const ansiCyan = "\033[36m"
+const ansiGreen = "\033[32m"
+
+// roleLabel returns the label printed before the messages of role. it's only colored when
+// writing to a terminal, so that the escape codes don't end up in files
+func (s *Session) roleLabel(w io.Writer, role string) string {
+
+	label, color := "[You]: ", ansiCyan
+	if role == assistantRole {
+		label, color = "[Assistant]: ", ansiGreen
+	}
+
+	if !s.colorLabels || w != io.Writer(os.Stdout) {
+		return label
+	}
+
+	return color + label + ansiReset
+}
+
+// isTerminal reports whether f is a terminal rather than e.g. a file or a pipe
+func isTerminal(f *os.File) bool {
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// turnPrefix returns the turn number and the local time in -timestamps mode
+func (s *Session) turnPrefix() string {
+
+	if !s.opts.Timestamps {
+		return ""
+	}
+
+	return fmt.Sprintf("[#%d %s] ", s.turn, time.Now().Format(time.TimeOnly))
+}
+
+const stopReasonEndTurn = "end_turn"
+const stopReasonMaxTokens = "max_tokens"
+const stopReasonStopSequence = "stop_sequence"
+
+// stopReasonNote explains why a response ended, unless it finished normally
+func stopReasonNote(resp bedrock.Claude3Response) string {
+	switch resp.StopReason {
+	case "", stopReasonEndTurn:
+		return ""
+	case stopReasonMaxTokens:
+		return "[response truncated: max_tokens reached, increase -max-tokens]"
+	case stopReasonStopSequence:
+		return fmt.Sprintf("[response stopped at stop sequence %q]", resp.StopSequence)
+	default:
+		return fmt.Sprintf("[response stopped: %s]", resp.StopReason)
+	}
+}
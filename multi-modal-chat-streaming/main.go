@@ -1,340 +1,340 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/abhirockzz/claude3-bedrock-go/bedrock"
+	"github.com/abhirockzz/claude3-bedrock-go/internal/chat"
 )
 
-const defaultRegion = "us-east-1"
+const userRole = "user"
+const contentTypeImage = "image"
+const contentTypeDocument = "document"
+const mediaTypePDF = "application/pdf"
+
+// maxImages is the maximum number of images Claude accepts in a request
+const maxImages = 20
 
-var brc *bedrockruntime.Client
+// const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
+const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
 
-func init() {
+func main() {
+	cfg := bedrock.DefaultConfig(modelID)
+	cfg.RegisterFlags(flag.CommandLine)
+	opts := chat.DefaultOptions()
+	opts.RegisterFlags(flag.CommandLine)
+	image := flag.String("image", "", "image to send along with -prompt (local path, url or - to read it from stdin), optionally followed by :<media type>")
+	imageWarnThreshold := flag.Int("image-warn-threshold", 15, "warn once the conversation has this many images, as requests are limited to 20")
+	imageTimeout := flag.Duration("image-timeout", 30*time.Second, "timeout for fetching images and documents from a url")
+	flag.Parse()
 
-	region := os.Getenv("AWS_REGION")
-	if region == "" {
-		region = defaultRegion
+	err := opts.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *image != "" && opts.Prompt == "" {
+		log.Fatal("-prompt is required along with -image")
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	err = chat.InitLogger(opts.LogLevel, opts.Verbose)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	brc = bedrockruntime.NewFromConfig(cfg)
-}
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bedrock.IsInferenceProfile(cfg.ModelID) {
+		slog.Info("using an inference profile", "id", cfg.ModelID)
+	}
 
-var verbose *bool
+	httpClient := bedrock.NewHTTPClient(*imageTimeout)
 
-const userRole = "user"
-const assistantRole = "assistant"
-const contentTypeText = "text"
+	session, err := chat.New(context.Background(), cfg, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer session.Close()
 
-// const modelID = "anthropic.claude-3-sonnet-20240229-v1:0"
-const modelID = "anthropic.claude-3-haiku-20240307-v1:0"
+	payload, err := opts.Request(cfg, flag.CommandLine)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func main() {
-	verbose = flag.Bool("verbose", false, "setting to true will log messages being exchanged with LLM")
-	flag.Parse()
+	if opts.Prompt != "" {
+		msg := bedrock.Message{
+			Role: userRole,
+		}
+
+		if *image != "" {
+			imageContent, err := bedrock.NewImageContent(httpClient, *image)
+			if err != nil {
+				log.Fatal(err)
+			}
+			msg.Content = append(msg.Content, imageContent)
+			logImage(*image, imageContent, 1)
+		}
+		msg.Content = append(msg.Content, bedrock.TextContent(opts.Prompt))
 
-	reader := bufio.NewReader(os.Stdin)
+		err = session.SendPrompt(payload, msg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	prompt := func() string {
+		return fmt.Sprintf("Choose your message type%s - Text (enter 1), Image (enter 2) or PDF document (enter 3), "+
+			"/save <filename> to save the last response, /continue to continue it, /edit to edit it or /model <id> to switch models", session.ActiveModel())
+	}
 
-	payload := Claude3Request{
-		AnthropicVersion: "bedrock-2023-05-31",
-		MaxTokens:        1024,
+	err = session.Run(payload, prompt, menu(session, httpClient, *imageWarnThreshold))
+	if err != nil {
+		log.Fatal(err)
 	}
+}
 
-	for {
-		fmt.Print("\nChoose your message type - Text (enter 1) or Image (enter 2): ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
+// menu returns a chat.Composer that asks for the type of message to send (text, images or a PDF
+// document), and then for its contents. Images and documents given as urls are fetched with httpClient
+func menu(session *chat.Session, httpClient *http.Client, imageWarnThreshold int) chat.Composer {
 
-		msg := Message{
+	return func(input string, history []bedrock.Message) (bedrock.Message, bool, error) {
+
+		msg := bedrock.Message{
 			Role: userRole,
 		}
 
 		if input == "1" {
 
-			fmt.Print("\nEnter your message: ")
-			text, _ := reader.ReadString('\n')
-			text = strings.TrimSpace(text)
-
-			textContent := Content{
-				Type: "text",
-				Text: text,
+			fmt.Fprintf(os.Stderr, "\nEnter your message (%s for multiple lines): ", chat.MultilineDelimiter)
+			text, err := session.ReadMessage()
+			if err != nil {
+				return msg, false, err
+			}
+			if text == "" {
+				// an empty message would be rejected
+				return msg, false, nil
 			}
-			msg.Content = append(msg.Content, textContent)
+
+			msg.Content = append(msg.Content, bedrock.TextContent(text))
 
 		} else if input == "2" {
 
+			// images sent earlier in the conversation count towards the limit as well
+			imageCount := countImages(history)
+			if imageCount >= maxImages {
+				fmt.Fprintf(os.Stderr, "\nthe conversation already has %d images, which is the maximum Claude accepts\n", imageCount)
+				return msg, false, nil
+			}
+
 			for {
-				fmt.Print("\nEnter the image source (local path or url): ")
-				path, _ := reader.ReadString('\n')
-				path = strings.TrimSpace(path)
+				fmt.Fprint(os.Stderr, "\nEnter the image source (local path, glob pattern or url, optionally followed by :<media type>): ")
+				path, err := session.ReadLine()
+				if err != nil {
+					return msg, false, err
+				}
+				// the chat reads its input from stdin, so the image can't come from there as well
+				if path == "-" || strings.HasPrefix(path, "-:image/") {
+					fmt.Fprintln(os.Stderr, "\nimages can't be read from stdin in the chat, use -image - along with -prompt instead")
+					return msg, false, nil
+				}
 
-				imageContents, err := readImageAsBase64(path)
+				sources, err := expandImageSource(path)
 				if err != nil {
-					log.Fatal(err)
+					fmt.Fprintln(os.Stderr, "\ncould not add the images:", err)
+					return msg, false, nil
+				}
+				if len(sources) > maxImages-imageCount {
+					fmt.Fprintf(os.Stderr, "\nonly the first %d will be added, Claude accepts at most %d images\n", maxImages-imageCount, maxImages)
+					sources = sources[:maxImages-imageCount]
 				}
 
-				imageContent := Content{Type: "image", Source: &Source{
-					Type:      "base64",
-					MediaType: "image/jpeg",
-					Data:      imageContents,
-				}}
-				msg.Content = append(msg.Content, imageContent)
+				for _, source := range sources {
+					imageContent, err := bedrock.NewImageContent(httpClient, source)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, "\ncould not add the image:", err)
+						return msg, false, nil
+					}
+					msg.Content = append(msg.Content, imageContent)
+					imageCount++
+					logImage(source, imageContent, imageCount)
 
-				fmt.Print("\nWould you like to add more images? enter yes or no: ")
-				yesOrNo, _ := reader.ReadString('\n')
-				yesOrNo = strings.TrimSpace(yesOrNo)
+					if imageCount == imageWarnThreshold && imageCount < maxImages {
+						fmt.Fprintf(os.Stderr, "\nwarning: the conversation has %d images, Claude accepts at most %d\n", imageCount, maxImages)
+					}
+				}
+
+				yesOrNo := "no"
+				if imageCount < maxImages {
+					fmt.Fprint(os.Stderr, "\nWould you like to add more images? enter yes or no: ")
+					yesOrNo, err = session.ReadLine()
+					if err != nil {
+						return msg, false, err
+					}
+				} else {
+					fmt.Fprintf(os.Stderr, "\nreached the maximum of %d images, no more can be added\n", maxImages)
+				}
 
 				if yesOrNo == "no" {
-					fmt.Print("\nWhat would you like to ask about the image(s)? : ")
-					q, _ := reader.ReadString('\n')
-					q = strings.TrimSpace(q)
+					fmt.Fprint(os.Stderr, "\nWhat would you like to ask about the image(s)? : ")
+					q, err := session.ReadMessage()
+					if err != nil {
+						return msg, false, err
+					}
 
-					textContent := Content{
-						Type: "text",
-						Text: q,
+					// the images are sent on their own without a question, as an empty text block would be rejected
+					if q != "" {
+						msg.Content = append(msg.Content, bedrock.TextContent(q))
 					}
-					msg.Content = append(msg.Content, textContent)
 
 					break
 				} else if yesOrNo == "yes" {
 					continue
 				} else {
-					log.Fatal("invalid option. enter yes or no. start over again")
+					fmt.Fprintln(os.Stderr, "\ninvalid option. enter yes or no. start over again")
+					return msg, false, nil
 				}
 			}
 
-		} else {
-			log.Fatal("invalid option. enter 1 or 2. start over again")
-		}
+		} else if input == "3" {
 
-		payload.Messages = append(payload.Messages, msg)
+			fmt.Fprint(os.Stderr, "\nEnter the PDF document source (local path or url): ")
+			path, err := session.ReadLine()
+			if err != nil {
+				return msg, false, err
+			}
+
+			documentContents, err := readDocumentAsBase64(httpClient, path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "\ncould not add the document:", err)
+				return msg, false, nil
+			}
 
-		response, err := send(payload)
+			documentContent := bedrock.Content{Type: contentTypeDocument, Source: &bedrock.Source{
+				Type:      "base64",
+				MediaType: mediaTypePDF,
+				Data:      documentContents,
+			}}
+			msg.Content = append(msg.Content, documentContent)
 
-		if err != nil {
-			log.Fatal(err)
-		}
+			fmt.Fprint(os.Stderr, "\nWhat would you like to ask about the document? : ")
+			q, err := session.ReadMessage()
+			if err != nil {
+				return msg, false, err
+			}
 
-		//fmt.Println("[Assistant]:", response)
+			// as with images, the document is sent on its own without a question
+			if q != "" {
+				msg.Content = append(msg.Content, bedrock.TextContent(q))
+			}
 
-		respMsg := Message{
-			Role: assistantRole,
-			Content: []Content{
-				{
-					Type: contentTypeText,
-					Text: response,
-				},
-			},
+		} else {
+			fmt.Fprintln(os.Stderr, "\ninvalid option. enter 1, 2 or 3. start over again")
+			return msg, false, nil
 		}
-		payload.Messages = append(payload.Messages, respMsg)
 
+		return msg, true, nil
 	}
 }
 
-func send(payload Claude3Request) (string, error) {
+// logImage logs an image that was added to a message, along with the number of images in the
+// conversation so far (shown with -verbose)
+func logImage(source string, image bedrock.Content, count int) {
 
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", err
+	if strings.HasPrefix(source, "data:") {
+		// the whole image is in the URI
+		source = "data URI"
 	}
 
-	if *verbose {
-		fmt.Println("[request payload]", string(payloadBytes))
-	}
+	data := image.Source.Data
+	size := base64.StdEncoding.DecodedLen(len(data)) - strings.Count(data[max(len(data)-2, 0):], "=")
 
-	output, err := brc.InvokeModelWithResponseStream(context.Background(), &bedrockruntime.InvokeModelWithResponseStreamInput{
-		Body:        payloadBytes,
-		ModelId:     aws.String(modelID),
-		ContentType: aws.String("application/json"),
-	})
+	slog.Debug("added image", "source", source, "media_type", image.Source.MediaType, "bytes", size, "count", count)
+}
 
-	if err != nil {
-		return "", err
-	}
+// expandImageSource returns the files matching source if it's a glob pattern, e.g. ./shots/*.png,
+// and source itself otherwise. A :<media type> suffix applies to each of the matches, and a file
+// that exists is taken as it is, even if its name looks like a pattern, e.g. shot[1].png. A
+// pattern that matches no files is an error
+func expandImageSource(source string) ([]string, error) {
 
-	fmt.Print("[Assistant]: ")
+	if strings.HasPrefix(source, "data:") {
+		return []string{source}, nil
+	}
 
-	resp, err := processStreamingOutput(output, func(ctx context.Context, part []byte) error {
-		fmt.Print(string(part))
-		return nil
-	})
+	path, mediaType := bedrock.SplitMediaType(source)
+	if bedrock.IsURL(path) || !strings.ContainsAny(path, "*?[") {
+		return []string{source}, nil
+	}
 
+	pattern, err := bedrock.LocalPath(path)
 	if err != nil {
-		log.Fatal("streaming output processing error: ", err)
+		return nil, err
 	}
 
-	return resp.ResponseContent[0].Text, nil
-}
-
-type Claude3Request struct {
-	AnthropicVersion string    `json:"anthropic_version"`
-	MaxTokens        int       `json:"max_tokens"`
-	Messages         []Message `json:"messages"`
-	Temperature      float64   `json:"temperature,omitempty"`
-	TopP             float64   `json:"top_p,omitempty"`
-	TopK             int       `json:"top_k,omitempty"`
-	StopSequences    []string  `json:"stop_sequences,omitempty"`
-	SystemPrompt     string    `json:"system,omitempty"`
-}
-
-type Content struct {
-	Type   string  `json:"type,omitempty"`
-	Source *Source `json:"source,omitempty"`
-	Text   string  `json:"text,omitempty"`
-}
-type Source struct {
-	Type      string `json:"type,omitempty"`
-	MediaType string `json:"media_type,omitempty"`
-	Data      string `json:"data,omitempty"`
-}
-type Message struct {
-	Role    string    `json:"role,omitempty"`
-	Content []Content `json:"content,omitempty"`
-}
-
-type Claude3Response struct {
-	ID              string            `json:"id,omitempty"`
-	Model           string            `json:"model,omitempty"`
-	Type            string            `json:"type,omitempty"`
-	Role            string            `json:"role,omitempty"`
-	ResponseContent []ResponseContent `json:"content,omitempty"`
-	StopReason      string            `json:"stop_reason,omitempty"`
-	StopSequence    string            `json:"stop_sequence,omitempty"`
-	Usage           Usage             `json:"usage,omitempty"`
-}
-type ResponseContent struct {
-	Type string `json:"type,omitempty"`
-	Text string `json:"text,omitempty"`
-}
-type Usage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
-}
-
-type PartialResponse struct {
-	Type    string                 `json:"type"`
-	Message PartialResponseMessage `json:"message,omitempty"`
-	Index   int                    `json:"index,omitempty"`
-	Delta   Delta                  `json:"delta,omitempty"`
-	Usage   PartialResponseUsage   `json:"usage,omitempty"`
-}
+	_, err = os.Stat(pattern)
+	if err == nil {
+		return []string{source}, nil
+	}
 
-type PartialResponseMessage struct {
-	ID           string               `json:"id,omitempty"`
-	Type         string               `json:"type,omitempty"`
-	Role         string               `json:"role,omitempty"`
-	Content      []interface{}        `json:"content,omitempty"`
-	Model        string               `json:"model,omitempty"`
-	StopReason   string               `json:"stop_reason,omitempty"`
-	StopSequence interface{}          `json:"stop_sequence,omitempty"`
-	Usage        PartialResponseUsage `json:"usage,omitempty"`
-}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", path, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files matched %s", path)
+	}
+	fmt.Fprintf(os.Stderr, "\n%d files matched %s\n", len(matches), path)
 
-type PartialResponseUsage struct {
-	InputTokens  int `json:"input_tokens,omitempty"`
-	OutputTokens int `json:"output_tokens,omitempty"`
-}
+	if mediaType != "" {
+		for i := range matches {
+			matches[i] += ":" + mediaType
+		}
+	}
 
-type Delta struct {
-	Type       string `json:"type,omitempty"`
-	Text       string `json:"text,omitempty"`
-	StopReason string `json:"stop_reason,omitempty"`
+	return matches, nil
 }
 
-const partialResponseTypeContentBlockDelta = "content_block_delta"
-const partialResponseTypeMessageStart = "message_start"
-const partialResponseTypeMessageDelta = "message_delta"
-
-type StreamingOutputHandler func(ctx context.Context, part []byte) error
+// countImages returns the number of images in messages
+func countImages(messages []bedrock.Message) int {
 
-func processStreamingOutput(output *bedrockruntime.InvokeModelWithResponseStreamOutput, handler StreamingOutputHandler) (Claude3Response, error) {
-
-	var combinedResult string
-	resp := Claude3Response{
-		Type:            "message",
-		Role:            "assistant",
-		Model:           "claude-3-sonnet-28k-20240229",
-		ResponseContent: []ResponseContent{{Type: contentTypeText}}}
-
-	for event := range output.GetStream().Events() {
-		switch v := event.(type) {
-		case *types.ResponseStreamMemberChunk:
-
-			var pr PartialResponse
-			err := json.NewDecoder(bytes.NewReader(v.Value.Bytes)).Decode(&pr)
-			if err != nil {
-				return resp, err
+	count := 0
+	for _, msg := range messages {
+		for _, content := range msg.Content {
+			if content.Type == contentTypeImage {
+				count++
 			}
-
-			if pr.Type == partialResponseTypeContentBlockDelta {
-				handler(context.Background(), []byte(pr.Delta.Text))
-				combinedResult += pr.Delta.Text
-			} else if pr.Type == partialResponseTypeMessageStart {
-				resp.ID = pr.Message.ID
-				resp.Usage.InputTokens = pr.Message.Usage.InputTokens
-			} else if pr.Type == partialResponseTypeMessageDelta {
-				resp.StopReason = pr.Delta.StopReason
-				resp.Usage.OutputTokens = pr.Message.Usage.OutputTokens
-			}
-
-		case *types.UnknownUnionMember:
-			fmt.Println("unknown tag:", v.Tag)
-
-		default:
-			fmt.Println("union is nil or unknown type")
 		}
 	}
 
-	//resp.ResponseContent = []ResponseContent{}
-	resp.ResponseContent[0].Text = combinedResult
-
-	return resp, nil
+	return count
 }
 
-func readImageAsBase64(source string) (string, error) {
-
-	var imageBytes []byte
+func readDocumentAsBase64(httpClient *http.Client, source string) (string, error) {
 
-	if strings.Contains(source, "http") {
-		resp, err := http.Get(source)
-		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
+	documentBytes, err := bedrock.ReadSource(httpClient, source, mediaTypePDF)
+	if err != nil {
+		return "", err
+	}
 
-		imageBytes, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		//assume it's local
-		var err error
-		imageBytes, err = os.ReadFile(source)
-		if err != nil {
-			return "", err
-		}
+	if !bytes.HasPrefix(documentBytes, []byte("%PDF-")) {
+		return "", fmt.Errorf("%s is not a PDF document", source)
 	}
 
-	encodedString := base64.StdEncoding.EncodeToString(imageBytes)
+	encodedString := base64.StdEncoding.EncodeToString(documentBytes)
 
 	return encodedString, nil
 }